@@ -5,15 +5,26 @@ import (
 	"github.com/felixangell/phi/internal/cfg"
 	"github.com/felixangell/phi/internal/editor"
 	"github.com/felixangell/strife"
+	"io/ioutil"
 	"log"
+	"os"
 	"runtime"
 	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "--check-config" {
+		os.Exit(checkConfig())
+	}
+
 	runtime.LockOSThread()
 
-	config := cfg.NewDefaultConfig()
+	// a broken config shouldn't stop the editor from
+	// starting, we just fall back to the defaults.
+	config, err := cfg.LoadConfig()
+	if err != nil {
+		log.Println("Failed to load config:", err.Error())
+	}
 
 	windowConfig := strife.DefaultConfig()
 	windowConfig.Accelerated = config.Render.Accelerated
@@ -88,6 +99,27 @@ func main() {
 	}
 }
 
+// checkConfig prints every problem with the config
+// and returns the status to exit with.
+func checkConfig() int {
+	log.SetOutput(ioutil.Discard)
+
+	_, err := cfg.LoadConfig()
+	if err == nil {
+		fmt.Println(cfg.ConfigPath()+":", "ok")
+		return 0
+	}
+
+	errs, ok := err.(cfg.ConfigErrors)
+	if !ok {
+		errs = cfg.ConfigErrors{err}
+	}
+	for _, err := range errs {
+		fmt.Println(err.Error())
+	}
+	return 1
+}
+
 func calcScaledWindowDimension(width, height float32) (int, int) {
 	dpi, defDpi := strife.GetDisplayDPI(0)
 
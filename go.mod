@@ -1,8 +1,9 @@
 module github.com/felixangell/phi
 
-go 1.14
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/atotto/clipboard v0.1.2
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/felixangell/strife v0.2.2
@@ -11,5 +12,5 @@ require (
 	github.com/stretchr/testify v1.6.1
 	github.com/veandco/go-sdl2 v0.4.5
 	golang.org/x/sys v0.0.0-20210110051926-789bb1bd4061 // indirect
-	golang.org/x/text v0.3.5 // indirect
+	golang.org/x/text v0.3.5
 )
@@ -10,16 +10,15 @@ import (
 	"github.com/felixangell/strife"
 	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/veandco/go-sdl2/sdl"
-	"io/ioutil"
 	"log"
 	"math"
 	"os"
-	"path"
 	"regexp"
 	"runtime"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -135,6 +134,36 @@ type Buffer struct {
 	ex, ey       int
 	modified     bool
 	autoComplete *AutoCompleteBox
+	undoRun      undoRun
+
+	// the hash of the file on disk and the
+	// undo state that it was saved at.
+	diskHash  string
+	diskState int
+
+	// to spot the file being changed by something else
+	diskModTime time.Time
+	diskSize    int64
+
+	// where the table was at when the file was last
+	// saved, to find the lines that have been edited.
+	saveMark piecetable.EditMark
+
+	// the document as it was last loaded or saved, changes
+	// made on disk are merged into the buffer against it.
+	base *piecetable.Snapshot
+
+	// if the file has been deleted or moved on disk
+	fileState fileState
+
+	// unsaved changes are journalled so they can be recovered
+	journal journal
+
+	// the encoding the file is saved in
+	encoding *textEncoding
+
+	// shown in the meta panel
+	status statusMessage
 }
 
 // NewBuffer creates a new buffer with the given configurations
@@ -155,6 +184,7 @@ func NewBuffer(conf *cfg.PhiEditorConfig, buffOpts BufferConfig, parent *BufferV
 		curs:         nil,
 		cfg:          config,
 		table:        piecetable.MakePieceTable(""),
+		encoding:     utf8Encoding,
 		buffOpts:     buffOpts,
 		filePath:     "",
 		cam:          &camera{0, 0, 0, 0},
@@ -172,6 +202,15 @@ type selection struct {
 	ex, ey int
 }
 
+// clamp moves the selection back inside the
+// document if it has got shorter underneath it.
+func (s *selection) clamp() {
+	table := s.parent.table
+	last := table.LineCount() - 1
+	s.sy, s.ey = min(s.sy, last), min(s.ey, last)
+	s.sx, s.ex = min(s.sx, table.LineLen(s.sy)), min(s.ex, table.LineLen(s.ey))
+}
+
 func (s *selection) renderAt(ctx *strife.Renderer, _ int, _ int) {
 	ctx.SetColor(strife.Blue)
 
@@ -181,7 +220,7 @@ func (s *selection) renderAt(ctx *strife.Renderer, _ int, _ int) {
 
 	// renders the highlighting for a line.
 	for y := 0; y < yd; y++ {
-		lineLen := s.parent.table.Lines[s.sy+y].Len()
+		lineLen := s.parent.table.LineLen(s.sy + y)
 
 		// purely for the aesthetics?
 		// feel like this will create bugs.
@@ -216,26 +255,93 @@ func (b *Buffer) reload() {
 		return
 	}
 
-	// if the file has modifications made to it
-	// ask if the user wants to reload the file or not
+	// if the file has modifications made to it we
+	// merge the changes on disk in rather than lose them,
 	// otherwise re-load it anyway.
 	if b.modified {
-		// ok := dialog.Message("This file has been modified, would you like to reload?").YesNo()
-		panic("this should show a file modified thing but this has been removed for now!")
+		b.mergeFromDisk()
+	} else {
+		// the file may be half written or gone again by the time
+		// we get to it, the table we have is kept if so.
+		if err := b.loadTable(b.filePath, b.encoding); err != nil {
+			b.showError("Failed to reload %s: %s", b.filePath, err.Error())
+			return
+		}
+		b.modified = false
 	}
 
-	contents, err := ioutil.ReadFile(b.filePath)
+	// the file may be shorter than it was, so the cursor
+	// and selection could be past the end of it now.
+	b.clampCursor()
+	if lastSelection != nil && lastSelection.parent == b {
+		lastSelection.clamp()
+	}
+}
+
+// loadTable loads the file into a new piece table, decoding it
+// from the given encoding or one that is guessed if it is nil.
+// big utf-8 files are loaded in the background and the buffer is
+// read only until they have finished loading.
+func (b *Buffer) loadTable(filePath string, enc *textEncoding) error {
+	if enc == nil {
+		var err error
+		if enc, err = sniffEncoding(filePath); err != nil {
+			return err
+		}
+	}
+
+	if enc.enc != nil {
+		table, data, err := decodeFile(filePath, enc)
+		if err != nil {
+			return err
+		}
+		b.setTable(table, enc)
+		b.diskHash = hashContents(data)
+		b.recordDiskInfo()
+		return nil
+	}
+
+	table, err := piecetable.LoadFile(filePath)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	b.setTable(table, enc)
 
-	b.table = piecetable.MakePieceTable(string(contents))
+	// we don't hash files that are still loading as we'd have
+	// to read the whole thing, so they wont get their undo
+	// history back until they have been saved.
+	if !table.ReadOnly() {
+		if hash, err := hashFile(filePath); err == nil {
+			b.diskHash = hash
+		}
+	}
+	b.recordDiskInfo()
+	return nil
+}
 
-	// TODO perhaps when we reload the current line might not exist or something
-	// try and set the cursor to what it was before but maybe make sure its not out
-	// of bounds, etc.
+func (b *Buffer) setTable(table *piecetable.PieceTable, enc *textEncoding) {
+	b.table = table
+	b.encoding = enc
+	b.undoRun = undoRun{}
+	b.diskHash, b.diskState = "", table.CurrentState()
+	b.saveMark = table.EditMark()
 
-	b.modified = false
+	// if the file is still loading the base is
+	// taken once it has finished, see pollLoading.
+	b.base = nil
+	if !table.ReadOnly() {
+		b.base = table.Snapshot()
+	}
+}
+
+// pollLoading swaps in the rest of the file if it has finished
+// loading, this should be used rather than the tables Loading.
+func (b *Buffer) pollLoading() (bool, float64) {
+	loading, progress := b.table.Loading()
+	if !loading && b.base == nil {
+		b.base = b.table.Snapshot()
+	}
+	return loading, progress
 }
 
 // OpenFile will open the given file path into this buffer.
@@ -245,14 +351,6 @@ func (b *Buffer) OpenFile(filePath string) {
 
 	log.Println("Opening file ", filePath)
 
-	ext := path.Ext(filePath)
-
-	var err error
-	b.languageInfo, err = b.cfg.GetSyntaxConfig(ext)
-	if err != nil {
-		log.Println(err.Error())
-	}
-
 	// if the file doesn't exist, try to create it before reading it
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		f, err := os.Create(filePath)
@@ -265,15 +363,21 @@ func (b *Buffer) OpenFile(filePath string) {
 		}
 	}
 
-	contents, err := ioutil.ReadFile(filePath)
-	if err != nil {
+	if err := b.loadTable(filePath, nil); err != nil {
 		panic(err)
 	}
 
+	// the first line is for files that have no extension
+	// but name their interpreter, e.g. #!/bin/sh
+	b.languageInfo = b.cfg.SyntaxFor(filePath, b.table.Line(0))
+	if b.languageInfo == nil {
+		log.Println("No language for", filePath)
+	}
+
 	// add the file to the watcher.
 	b.parent.registerFile(filePath, b)
 
-	b.table = piecetable.MakePieceTable(string(contents))
+	b.loadHistory()
 
 	// because appendLine sets modified to true
 	// we should reset this to false since we've
@@ -283,55 +387,43 @@ func (b *Buffer) OpenFile(filePath string) {
 
 func (b *Buffer) setLine(idx int, val string) {
 	b.modified = true
+	b.endRun()
 
-	b.table.Lines[idx] = piecetable.NewLine(val, b.table)
+	b.table.SetLine(idx, val)
 	if b.curs.y == idx {
 		b.moveToEndOfLine()
 	}
 }
 
-func (b *Buffer) appendLineAt(val *piecetable.Line, idx int) {
-	b.modified = true
-
-	b.table.Lines = append(b.table.Lines, val)
-	copy(b.table.Lines[idx+1:], b.table.Lines[idx:])
-	b.table.Lines[idx] = val
-}
-
-func (b *Buffer) appendStringAt(val string, idx int) {
-	b.appendLineAt(piecetable.NewLine(val, b.table), idx)
-}
-
 // appendLine adds a string to the end of
 // the buffer.
 func (b *Buffer) appendLine(val string) {
 	b.modified = true
+	b.endRun()
 
-	b.table.Lines = append(b.table.Lines, piecetable.NewLine(val, b.table))
+	b.table.InsertLine(b.table.LineCount(), val)
 	// because we've added a new line
 	// we have to set the x to the start
 	b.curs.x = 0
 }
 
-// inserts a string, handling all of the newlines etc
-func (b *Buffer) insertString(idx int, val string) {
+// inserts a string at the cursor, handling all of
+// the newlines etc. the cursor is moved to the end
+// of the inserted string.
+func (b *Buffer) insertString(val string) {
 	b.modified = true
+	b.endRun()
 
-	lines := strings.Split(val, "\n")
-
-	for _, l := range lines {
-		b.table.Lines = append(b.table.Lines, piecetable.NewLine("", b.table))
-		copy(b.table.Lines[b.curs.y+idx+1:], b.table.Lines[b.curs.y+idx:])
-		b.table.Lines[b.curs.y+idx] = piecetable.NewLine(l, b.table)
-		b.moveDown()
-	}
-
+	end := b.table.InsertAt(piecetable.Pos{Line: b.curs.y, Col: b.curs.x}, val)
+	b.curs.SetPos(end.Col, end.Line)
 }
 
 func (b *Buffer) insertRune(r rune) {
 	b.modified = true
+	b.continueRun(typingEdit, r)
 	b.table.Insert(string(r), b.curs.y, b.curs.x)
 	b.moveRight()
+	b.markRun()
 }
 
 // TODO handle EVERYTHING but for now im handling
@@ -387,17 +479,13 @@ var altAlternative = map[rune]rune{
 
 func (b *Buffer) deleteLine() {
 	b.modified = true
+	b.endRun()
 
-	if len(b.table.Lines) > 1 {
-		b.table.Lines = remove(b.table.Lines, b.curs.y)
-	} else {
-		// we are on the first line
-		// and there is nothing else to delete
-		// so we just clear the line
-		b.table.Lines[b.curs.y] = piecetable.NewLine("", b.table)
-	}
+	// NOTE: if this is the only line
+	// the table will just clear it.
+	b.table.RemoveLine(b.curs.y)
 
-	if b.curs.y >= len(b.table.Lines) {
+	if b.curs.y >= b.table.LineCount() {
 		if b.curs.y > 0 {
 			b.moveUp()
 		}
@@ -472,9 +560,8 @@ func (b *Buffer) processTextInput(keyCode int) bool {
 	// brackets.
 	if b.cfg.Editor.MatchBraces {
 		if keyValue == ')' || keyValue == '}' || keyValue == ']' {
-			currLine := b.table.Lines[b.curs.y]
-			if b.curs.x < currLine.Len() {
-				curr := b.table.Index(b.curs.y, b.curs.x+1)
+			if b.curs.x < b.table.LineLen(b.curs.y) {
+				curr := b.table.Index(b.curs.y, b.curs.x)
 				if curr == keyValue {
 					b.moveRight()
 					return true
@@ -486,6 +573,14 @@ func (b *Buffer) processTextInput(keyCode int) bool {
 	// HACK FIXME
 	b.modified = true
 
+	b.continueRun(typingEdit, keyValue)
+	defer b.markRun()
+
+	// the brace we insert below is
+	// undone along with this character
+	b.table.BeginGroup()
+	defer b.table.EndGroup()
+
 	b.table.Insert(string(keyValue), b.curs.y, b.curs.x)
 	b.moveRight()
 
@@ -517,10 +612,6 @@ func (b *Buffer) processTextInput(keyCode int) bool {
 	return true
 }
 
-func remove(slice []*piecetable.Line, s int) []*piecetable.Line {
-	return append(slice[:s], slice[s+1:]...)
-}
-
 func (b *Buffer) deleteNext() {
 	b.moveRight()
 	b.deletePrev()
@@ -528,6 +619,13 @@ func (b *Buffer) deleteNext() {
 
 // FIXME clean this up!
 func (b *Buffer) deletePrev() {
+	if b.curs.x == 0 && b.curs.y == 0 {
+		return
+	}
+
+	b.continueRun(deletingEdit, b.runeBeforeCursor())
+	defer b.markRun()
+
 	if b.curs.x > 0 {
 		if b.cfg.Editor.HungryBackspace && b.curs.x >= int(b.cfg.Editor.TabSize) {
 			// cut out the last {TAB_SIZE} amount of characters
@@ -535,10 +633,13 @@ func (b *Buffer) deletePrev() {
 			tabSize := int(b.cfg.Editor.TabSize)
 
 			// render the line...
-			currLine := b.table.Lines[b.curs.y].String()
-			before := currLine[b.curs.x-tabSize:]
+			currLine := []rune(b.table.Line(b.curs.y))
+			before := string(currLine[b.curs.x-tabSize : b.curs.x])
 
 			if strings.HasPrefix(before, b.makeTab()) {
+				b.table.BeginGroup()
+				defer b.table.EndGroup()
+
 				// delete {TAB_SIZE} amount of characters
 				// from the cursors x pos
 				for i := 0; i < int(b.cfg.Editor.TabSize); i++ {
@@ -569,17 +670,24 @@ func (b *Buffer) deletePrev() {
 		// wrapping back the line?
 
 		// start of line, wrap to previous
-		val := b.table.Lines[b.curs.y].String()
-		b.table.Insert(val, b.curs.y-1, b.table.Lines[b.curs.y-1].Len())
+		prevLineLen := b.table.LineLen(b.curs.y - 1)
+		b.table.DeleteRange(
+			piecetable.Pos{Line: b.curs.y - 1, Col: prevLineLen},
+			piecetable.Pos{Line: b.curs.y, Col: 0},
+		)
 
-		b.table.Lines = append(b.table.Lines[:b.curs.y], b.table.Lines[b.curs.y+1:]...)
-
-		b.moveUp()
-		b.moveToEndOfLine()
+		b.curs.SetPos(prevLineLen, b.curs.y-1)
 	}
 }
 
 func (b *Buffer) deleteBeforeCursor() {
+	// this is undone in one go rather
+	// than as a run of deletes.
+	b.endRun()
+	b.table.BeginGroup()
+	defer b.table.EndGroup()
+	defer b.endRun()
+
 	// delete so we're at the end
 	// of the previous line
 	if b.curs.x == 0 {
@@ -594,13 +702,10 @@ func (b *Buffer) deleteBeforeCursor() {
 
 func (b *Buffer) moveLeft() {
 	if b.curs.x == 0 && b.curs.y > 0 {
-		b.curs.move(b.table.Lines[b.curs.y-1].Len(), -1)
+		b.curs.move(b.table.LineLen(b.curs.y-1), -1)
 	} else if b.curs.x > 0 {
-		str := b.table.Lines[b.curs.y].String()
-		inBounds := (b.curs.x-1 >= 0 && b.curs.x-1 < len(str))
-
 		charWidth := 1
-		if inBounds && str[b.curs.x-1] == '\t' {
+		if b.table.Index(b.curs.y, b.curs.x-1) == '\t' {
 			charWidth = 4
 		}
 
@@ -609,19 +714,18 @@ func (b *Buffer) moveLeft() {
 }
 
 func (b *Buffer) moveRight() {
-	currLineLength := b.table.Lines[b.curs.y].Len()
+	currLineLength := b.table.LineLen(b.curs.y)
 
-	if b.curs.x >= currLineLength && b.curs.y < len(b.table.Lines)-1 {
+	if b.curs.x >= currLineLength && b.curs.y < b.table.LineCount()-1 {
 		// we're at the end of the line and we have
 		// some lines after, let's wrap around
 		b.curs.move(-currLineLength, 0)
 		b.moveDown()
-	} else if b.curs.x < b.table.Lines[b.curs.y].Len() {
+	} else if b.curs.x < b.table.LineLen(b.curs.y) {
 		// we have characters to the right, let's move along
 
 		charWidth := 1
-		str := b.table.Lines[b.curs.y].String()[b.curs.x]
-		if str == '\t' {
+		if b.table.Index(b.curs.y, b.curs.x) == '\t' {
 			charWidth = 4
 		}
 
@@ -636,7 +740,7 @@ func (b *Buffer) moveToStartOfLine() {
 }
 
 func (b *Buffer) moveToEndOfLine() {
-	lineLen := b.table.Lines[b.curs.y].Len()
+	lineLen := b.table.LineLen(b.curs.y)
 
 	if b.curs.x > lineLen {
 		distToMove := b.curs.x - lineLen
@@ -666,7 +770,7 @@ func (b *Buffer) gotoLine(num int64) {
 func (b *Buffer) moveUp() {
 	if b.curs.y > 0 {
 		offs := 0
-		prevLineLen := b.table.Lines[b.curs.y-1].Len()
+		prevLineLen := b.table.LineLen(b.curs.y - 1)
 		if b.curs.x > prevLineLen {
 			offs = prevLineLen - b.curs.x
 		}
@@ -683,9 +787,9 @@ func (b *Buffer) moveUp() {
 }
 
 func (b *Buffer) moveDown() {
-	if b.curs.y < len(b.table.Lines)-1 {
+	if b.curs.y < b.table.LineCount()-1 {
 		offs := 0
-		nextLineLen := b.table.Lines[b.curs.y+1].Len()
+		nextLineLen := b.table.LineLen(b.curs.y + 1)
 		if b.curs.x > nextLineLen {
 			offs = nextLineLen - b.curs.x
 		}
@@ -704,22 +808,18 @@ func (b *Buffer) moveDown() {
 }
 
 func (b *Buffer) swapLineUp() bool {
+	b.endRun()
 	if b.curs.y > 0 {
-		currLine := b.table.Lines[b.curs.y]
-		prevLine := b.table.Lines[b.curs.y-1]
-		b.table.Lines[b.curs.y-1] = currLine
-		b.table.Lines[b.curs.y] = prevLine
+		b.table.SwapLines(b.curs.y, b.curs.y-1)
 		b.moveUp()
 	}
 	return true
 }
 
 func (b *Buffer) swapLineDown() bool {
-	if b.curs.y < len(b.table.Lines) {
-		currLine := b.table.Lines[b.curs.y]
-		nextLine := b.table.Lines[b.curs.y+1]
-		b.table.Lines[b.curs.y+1] = currLine
-		b.table.Lines[b.curs.y] = nextLine
+	b.endRun()
+	if b.curs.y < b.table.LineCount()-1 {
+		b.table.SwapLines(b.curs.y, b.curs.y+1)
 		b.moveDown()
 	}
 	return true
@@ -733,7 +833,7 @@ func (b *Buffer) scrollUp(lineScrollAmount int) {
 }
 
 func (b *Buffer) scrollDown(lineScrollAmount int) {
-	if b.cam.y < len(b.table.Lines) {
+	if b.cam.y < b.table.LineCount() {
 		b.cam.dy += lineScrollAmount
 	}
 }
@@ -753,7 +853,7 @@ func (b *Buffer) processSelection(key int) bool {
 	case sdl.K_LEFT:
 		if lastSelection.ex == 0 {
 			lastSelection.ey--
-			lineLen := b.table.Lines[lastSelection.ey].Len()
+			lineLen := b.table.LineLen(lastSelection.ey)
 			lastSelection.ex = lineLen
 		} else {
 			lastSelection.ex--
@@ -762,7 +862,7 @@ func (b *Buffer) processSelection(key int) bool {
 		b.moveLeft()
 		break
 	case sdl.K_RIGHT:
-		lineLen := b.table.Lines[lastSelection.ey].Len()
+		lineLen := b.table.LineLen(lastSelection.ey)
 		if lastSelection.ex == lineLen {
 			lastSelection.ey++
 			lastSelection.ex = 0
@@ -787,6 +887,19 @@ func (b *Buffer) processSelection(key int) bool {
 	return true
 }
 
+// isEditKey returns if the action for the
+// key will modify the buffer.
+func isEditKey(key int) bool {
+	switch key {
+	case strife.KEY_RETURN, strife.KEY_BACKSPACE, strife.KEY_TAB, strife.KEY_DELETE:
+		return true
+	case strife.KEY_UP, strife.KEY_DOWN:
+		// these swap lines around
+		return altDown
+	}
+	return false
+}
+
 // processes a key press. returns if a key was processed
 // or not, for example the letter 'a' could run through this
 // which is not an action key, therefore we return false
@@ -826,42 +939,12 @@ func (b *Buffer) processActionKey(key int) bool {
 		// clear the last runes
 		b.autoComplete.lastRunes = []rune{}
 
-		// START OF LINE:
-		if b.curs.x == 0 {
-			// we're at the start of a line, so we want to
-			// shift the line down and insert an empty line
-			// above it!
-			b.table.Lines = append(b.table.Lines, piecetable.NewLine("", b.table)) // grow
-			copy(b.table.Lines[b.curs.y+1:], b.table.Lines[b.curs.y:])             // shift
-			b.table.Lines[b.curs.y] = piecetable.NewLine("", b.table)              // set
-			b.moveDown()
-			return true
-		}
-
-		initialX := b.curs.x
-		prevLineLen := b.table.Lines[b.curs.y].Len()
-
-		// END OF LINE:
-		if initialX == prevLineLen {
-			b.appendStringAt("", b.curs.y+1)
-			b.moveDown()
-			b.moveToStartOfLine()
-			return true
-		}
+		b.endRun()
 
-		// we're not at the end of the line, but we're not at
-		// the start, i.e. we're SPLITTING the line
-		left := b.table.Lines[b.curs.y].String()
-		rightPart := left[initialX:]
-
-		for i := 0; i < len(rightPart); i++ {
-			// TODO POP in piecetable?
-			b.table.Delete(b.curs.y, len(left)-i)
-		}
-
-		b.appendStringAt(rightPart, b.curs.y+1)
-		b.moveDown()
-		b.moveToStartOfLine()
+		// the piece table will split the line
+		// for us if we are in the middle of it
+		b.table.InsertAt(piecetable.Pos{Line: b.curs.y, Col: b.curs.x}, "\n")
+		b.curs.SetPos(0, b.curs.y+1)
 
 	case strife.KEY_BACKSPACE:
 		// HACK FIXME
@@ -874,7 +957,7 @@ func (b *Buffer) processActionKey(key int) bool {
 		}
 
 	case strife.KEY_RIGHT:
-		currLineLength := b.table.Lines[b.curs.y].Len()
+		currLineLength := b.table.LineLen(b.curs.y)
 
 		if superDown {
 			for b.curs.x < currLineLength {
@@ -887,11 +970,9 @@ func (b *Buffer) processActionKey(key int) bool {
 		// this will move to the next blank or underscore
 		// character
 		if altDown {
-			line := b.table.Lines[b.curs.y]
-
 			i := b.curs.x + 1 // ?
 
-			for i < len(line.String())-1 {
+			for i < b.table.LineLen(b.curs.y)-1 {
 				curr := b.table.Index(b.curs.y, i)
 
 				switch curr {
@@ -982,6 +1063,7 @@ func (b *Buffer) processActionKey(key int) bool {
 	case strife.KEY_TAB:
 		// HACK FIXME
 		b.modified = true
+		b.endRun()
 
 		if b.cfg.Editor.TabsAreSpaces {
 			// make an empty rune array of TAB_SIZE, cast to string
@@ -997,9 +1079,9 @@ func (b *Buffer) processActionKey(key int) bool {
 		}
 
 	case strife.KEY_END:
-		currLine := b.table.Lines[b.curs.y]
-		if b.curs.x < currLine.Len() {
-			distToMove := currLine.Len() - b.curs.x
+		lineLen := b.table.LineLen(b.curs.y)
+		if b.curs.x < lineLen {
+			distToMove := lineLen - b.curs.x
 			for i := 0; i < distToMove; i++ {
 				b.moveRight()
 			}
@@ -1114,6 +1196,9 @@ func (b *Buffer) processLeftClick() {
 const ySpeed = 1
 
 func (b *Buffer) OnUpdate() bool {
+	// swaps in the rest of the file once it has loaded
+	b.pollLoading()
+
 	if cfg.DebugMode {
 		// these are some shitty animations only in debug mode
 		if b.cam.y < b.cam.dy {
@@ -1162,6 +1247,12 @@ func (b *Buffer) processInput(pred func(r int) bool) bool {
 			}
 		}
 
+		// nothing can be typed while the file is
+		// loading, but we can still move around.
+		if b.table.ReadOnly() && isEditKey(keyCode) {
+			return false
+		}
+
 		// try process this key input as an
 		// action first
 		actionPerformed := b.processActionKey(keyCode)
@@ -1177,6 +1268,10 @@ func (b *Buffer) processInput(pred func(r int) bool) bool {
 			return true
 		}
 
+		if b.table.ReadOnly() {
+			return false
+		}
+
 		textEntered := b.processTextInput(keyCode)
 		if textEntered {
 			return true
@@ -1213,7 +1308,7 @@ func lexFindMatches(matches *map[int]syntaxRuneInfo, currLine string, toMatch []
 		// hashset for match.
 		for _, ms := range toMatch {
 			if strings.Compare(ms, tok.Lexeme) == 0 {
-				(*matches)[tok.Start] = syntaxRuneInfo{bg, fg, len(tok.Lexeme)}
+				(*matches)[tok.Start] = syntaxRuneInfo{bg, fg, utf8.RuneCountInString(tok.Lexeme)}
 			}
 		}
 	}
@@ -1258,11 +1353,9 @@ func (b *Buffer) syntaxHighlightLine(currLine string) map[int]syntaxRuneInfo {
 	colours := make([]charColouring, len(b.languageInfo.Syntax))
 
 	idx := 0
-	for _, criteria := range b.languageInfo.Syntax {
-		colours[idx] = charColouring{
-			criteria.Background,
-			criteria.Foreground,
-		}
+	for name, criteria := range b.languageInfo.Syntax {
+		bg, fg := b.cfg.Theme.SyntaxColours(name, criteria)
+		colours[idx] = charColouring{bg, fg}
 		subjects[idx] = criteria
 		idx++
 	}
@@ -1274,17 +1367,22 @@ func (b *Buffer) syntaxHighlightLine(currLine string) map[int]syntaxRuneInfo {
 				a := currLine[charIndex:]
 
 				patt := lazyCompileRegExPattern(syntax.Pattern)
+				if patt == nil {
+					break
+				}
 
 				matched := patt.FindStringIndex(a)
 				if matched != nil {
-					if _, ok := matches[charIndex]; !ok {
+					// the matches are keyed by character rather than by byte
+					runeIndex := utf8.RuneCountInString(currLine[:charIndex+matched[0]])
+					if _, ok := matches[runeIndex]; !ok {
 						matchedStrLen := matched[1] - matched[0]
 
 						colouring := colours[syntaxIndex]
-						matches[charIndex+matched[0]] = syntaxRuneInfo{
+						matches[runeIndex] = syntaxRuneInfo{
 							colouring.bg,
 							colouring.fg,
-							matchedStrLen,
+							utf8.RuneCountInString(a[matched[0]:matched[1]]),
 						}
 
 						charIndex += matchedStrLen
@@ -1309,13 +1407,15 @@ func (b *Buffer) syntaxHighlightLine(currLine string) map[int]syntaxRuneInfo {
 var patts = map[string]*regexp.Regexp{}
 
 // FIXME
+// patterns that don't compile are cached as nil so
+// we only log them the once, Validate reports them.
 func lazyCompileRegExPattern(pattern string) *regexp.Regexp {
 	if cachedPattern, ok := patts[pattern]; ok {
 		return cachedPattern
 	}
 	compiledPattern, err := regexp.Compile(pattern)
 	if err != nil {
-		panic(err)
+		log.Println("Failed to compile pattern", pattern, err.Error())
 	}
 	patts[pattern] = compiledPattern
 	return compiledPattern
@@ -1362,12 +1462,12 @@ func (b *Buffer) renderAt(ctx *strife.Renderer, rx int, ry int) {
 
 	start := b.cam.y
 	upper := b.cam.y + visibleLines
-	if start > len(b.table.Lines) {
-		start = len(b.table.Lines)
+	if start > b.table.LineCount() {
+		start = b.table.LineCount()
 	}
 
-	if upper > len(b.table.Lines) {
-		upper = len(b.table.Lines)
+	if upper > b.table.LineCount() {
+		upper = b.table.LineCount()
 	}
 
 	// render the selection if any
@@ -1392,11 +1492,11 @@ func (b *Buffer) renderAt(ctx *strife.Renderer, rx int, ry int) {
 		b.curs.Render(ctx, rx, ry)
 	}
 
-	numLines := len(b.table.Lines)
+	numLines := b.table.LineCount()
 
 	var yCol int
-	for lineNum, rope := range b.table.Lines[start:upper] {
-		currLine := []rune(rope.String())
+	for lineNum := 0; lineNum < upper-start; lineNum++ {
+		currLine := []rune(b.table.Line(start + lineNum))
 
 		if visibleChars >= 0 {
 			// slice the visible characters only.
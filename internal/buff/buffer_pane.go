@@ -2,6 +2,8 @@ package buff
 
 import (
 	"fmt"
+	"strings"
+
 	"github.com/felixangell/phi/internal/cfg"
 	"github.com/felixangell/phi/internal/gui"
 	"github.com/felixangell/strife"
@@ -9,6 +11,9 @@ import (
 
 var metaPanelHeight = 32
 
+// the colour errors are shown in, in the meta panel
+const errorColour = 0xe06c75
+
 type BufferPane struct {
 	gui.BaseComponent
 	Buff *Buffer
@@ -61,7 +66,14 @@ func (b *BufferPane) renderMetaPanel(ctx *strife.Renderer) {
 		// TODO
 		syntaxName := "Undefined"
 
-		infoLine := fmt.Sprintf("Tab Size: %d    Syntax: %s", tabSize, syntaxName)
+		// these are all written out as the one line ending
+		lineEnding := b.Buff.table.LineEnding().String()
+		if b.Buff.table.MixedLineEndings() {
+			lineEnding += " (mixed)"
+		}
+
+		infoLine := fmt.Sprintf("Tab Size: %d    Syntax: %s    %s    %s",
+			tabSize, syntaxName, strings.ToUpper(b.Buff.encoding.name), lineEnding)
 		ctx.SetColor(strife.HexRGB(conf.Suggestion.Foreground))
 
 		ctx.SetFont(b.font)
@@ -74,13 +86,30 @@ func (b *BufferPane) renderMetaPanel(ctx *strife.Renderer) {
 			modified = '*'
 		}
 
-		infoLine := fmt.Sprintf("%s%c Line %d, Column %d", b.Buff.filePath, modified, b.Buff.curs.y+1, b.Buff.curs.x)
+		fileName := b.Buff.filePath
+		if b.Buff.fileState != fileOnDisk {
+			fileName = fmt.Sprintf("%s (%s)", fileName, b.Buff.fileState)
+		}
+
+		infoLine := fmt.Sprintf("%s%c Line %d, Column %d", fileName, modified, b.Buff.curs.y+1, b.Buff.curs.x)
+
+		if loading, progress := b.Buff.pollLoading(); loading {
+			infoLine = fmt.Sprintf("%s    Loading %d%%", infoLine, int(progress*100))
+		}
+
+		foreground := strife.HexRGB(conf.Suggestion.Foreground)
+		if status, ok := b.Buff.currentStatus(); ok {
+			infoLine = status.text
+			if status.isError {
+				foreground = strife.HexRGB(errorColour)
+			}
+		}
 
 		if cfg.DebugMode {
 			infoLine = fmt.Sprintf("%s, BuffIndex: %d", infoLine, b.Buff.index)
 		}
 
-		ctx.SetColor(strife.HexRGB(conf.Suggestion.Foreground))
+		ctx.SetColor(foreground)
 
 		ctx.SetFont(b.font)
 		_, strHeight := ctx.Text(infoLine, x+pad, mpY+(pad/2)+1)
@@ -0,0 +1,39 @@
+package buff
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadShorterFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "phi-reload-")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString(strings.Repeat("a long line of text\n", 20) + "the last line")
+	f.Close()
+
+	b := &Buffer{filePath: f.Name(), cam: &camera{}}
+	b.curs = newCursor(b)
+	assert.NoError(t, b.loadTable(f.Name(), nil))
+
+	last := b.table.LineCount() - 1
+	b.curs.SetPos(10, last)
+	assert.Equal(t, 10, b.curs.x)
+	lastSelection = &selection{b, 10, 5, 12, last}
+	defer func() { lastSelection = nil }()
+
+	assert.NoError(t, ioutil.WriteFile(f.Name(), []byte("short\nfile"), 0644))
+	b.reload()
+
+	assert.Equal(t, 1, b.curs.y)
+	assert.Equal(t, 4, b.curs.x)
+	assert.Equal(t, 1, lastSelection.sy)
+	assert.Equal(t, 4, lastSelection.sx)
+	assert.Equal(t, 1, lastSelection.ey)
+	assert.Equal(t, 4, lastSelection.ex)
+}
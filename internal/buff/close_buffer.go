@@ -18,6 +18,9 @@ func CloseBuffer(v *BufferView, commands []*lex.Token) BufferDirtyState {
 		// TODO basename?
 		text := fmt.Sprintf("Do you want to save the changes you made to %s?", b.filePath)
 
+		// at least the changes can be recovered next time.
+		b.writeJournal(true)
+
 		// TODO
 		panic(text)
 
@@ -30,6 +33,9 @@ func CloseBuffer(v *BufferView, commands []*lex.Token) BufferDirtyState {
 		// Save(v, []string{})
 	}
 
+	b.saveHistory()
+	b.removeJournal()
+	v.unregisterFile(b.filePath, b)
 	v.removeBuffer(b.index)
 	return false
 }
@@ -0,0 +1,156 @@
+package buff
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/felixangell/phi/internal/cfg"
+	"github.com/felixangell/phi/internal/command_handler"
+	"github.com/felixangell/phi/internal/gui"
+)
+
+// the debouncer key for any of the config files changing.
+// it can't be a real path as they are all absolute.
+const configChangeKey = "config"
+
+// configChangeEvent is sent when the config or
+// any of the syntax or theme files have changed.
+type configChangeEvent struct{}
+
+func (c *configChangeEvent) Process(view *BufferView) {
+	view.reloadConfig()
+}
+
+func (c *configChangeEvent) String() string {
+	return "config-change-event"
+}
+
+// isConfigPath returns if the path is the config file,
+// the syntax or theme directory or a file in either of them.
+func isConfigPath(path string) bool {
+	if path == watchPath(cfg.ConfigPath()) {
+		return true
+	}
+	for _, dir := range []string{cfg.SyntaxDir(), cfg.ThemeDir()} {
+		dir = watchPath(dir)
+		switch {
+		case path == dir:
+			return true
+		case filepath.Dir(path) == dir && filepath.Ext(path) == ".toml":
+			return true
+		}
+	}
+	return false
+}
+
+// watchConfig watches the config, syntax and theme directories,
+// the syntax and theme directories may not exist until later on.
+func (n *BufferView) watchConfig() {
+	for _, dir := range []string{cfg.ConfigDir(), cfg.SyntaxDir(), cfg.ThemeDir()} {
+		dir = watchPath(dir)
+		if n.configWatches[dir] {
+			continue
+		}
+		if err := n.watchDir(dir); err == nil {
+			n.configWatches[dir] = true
+		}
+	}
+}
+
+// loadFont is swapped out in the tests, as
+// there's no font to load without a window.
+var loadFont = gui.LoadDefaultFont
+
+// reloadConfig loads the config again and applies it to
+// everything. anything invalid in it is reported and left as
+// the default, if the font can't be loaded we keep the one we
+// have, and if any of the files can't be loaded the config we
+// have is kept as it is.
+func (n *BufferView) reloadConfig() {
+	n.watchConfig()
+
+	conf, err := cfg.LoadConfig()
+	if configUnreadable(err) {
+		n.configNotReloaded(err)
+		return
+	}
+	fontErr := loadConfigFont(conf, n.conf)
+
+	// the buffers all point at the views config,
+	// so they see the new one from here on.
+	*n.conf = *conf
+
+	command_handler.SetupCommandHandler(n.conf)
+
+	// the syntax may have changed for the same lines
+	syntaxCache = map[string]syntaxInfo{}
+
+	buffOpts := n.bufferConfig()
+	for _, buffPane := range n.buffers {
+		buffPane.font = buffOpts.font
+		buffPane.Buff.applyConfig(buffOpts)
+	}
+
+	// the palette has its own copy of the config
+	// so it is made again, keeping what it was doing.
+	old := n.commandPalette
+	n.commandPalette = NewCommandPalette(*n.conf, n)
+	if old.HasFocus() {
+		n.commandPalette.SetFocus(true)
+		n.commandPalette.parentBuff = old.parentBuff
+		n.commandPalette.buff.setLine(0, old.buff.table.Line(0))
+	}
+
+	if b := n.getCurrentBuff(); b != nil {
+		if fontErr != nil {
+			log.Println("Failed to load the font:", fontErr.Error())
+			b.showError("Reloaded config, but kept the old font: %s", fontErr.Error())
+		} else if err != nil {
+			log.Println("Problems in config:", err.Error())
+			b.showError("Reloaded config: %s", err.Error())
+		} else {
+			b.showMessage("Reloaded config")
+		}
+	}
+}
+
+// loadConfigFont loads the font in conf. if it can't be loaded
+// the font settings in current are kept, so that conf says which
+// font is in use, and the rest of conf is used as it is.
+func loadConfigFont(conf, current *cfg.PhiEditorConfig) error {
+	if err := loadFont(conf); err != nil {
+		conf.Editor.FontPath, conf.Editor.FontFace = current.Editor.FontPath, current.Editor.FontFace
+		return err
+	}
+	return nil
+}
+
+func (n *BufferView) configNotReloaded(err error) {
+	log.Println("Failed to reload config:", err.Error())
+	if b := n.getCurrentBuff(); b != nil {
+		b.showError("Config not reloaded: %s", err.Error())
+	}
+}
+
+// configUnreadable returns if any of the config couldn't be
+// loaded, e.g. it's half written or we can't read it, rather
+// than there only being invalid values in it.
+func configUnreadable(err error) bool {
+	errs, ok := err.(cfg.ConfigErrors)
+	if !ok {
+		return err != nil
+	}
+	for _, err := range errs {
+		if _, ok := err.(*cfg.ValidationError); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Buffer) applyConfig(buffOpts BufferConfig) {
+	b.buffOpts = buffOpts
+	if b.filePath != "" {
+		b.languageInfo = b.cfg.SyntaxFor(b.filePath, b.table.Line(0))
+	}
+}
@@ -0,0 +1,27 @@
+package buff
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/felixangell/phi/internal/cfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFontNotLoaded(t *testing.T) {
+	old := loadFont
+	t.Cleanup(func() { loadFont = old })
+	loadFont = func(*cfg.PhiEditorConfig) error {
+		return errors.New("no such font")
+	}
+
+	current := cfg.NewDefaultConfig()
+	conf := cfg.NewDefaultConfig()
+	conf.Editor.FontFace = "Missing"
+	conf.Editor.TabSize = 8
+
+	assert.Error(t, loadConfigFont(conf, current))
+	assert.Equal(t, current.Editor.FontFace, conf.Editor.FontFace)
+	assert.Equal(t, current.Editor.FontPath, conf.Editor.FontPath)
+	assert.Equal(t, 8, conf.Editor.TabSize)
+}
@@ -0,0 +1,150 @@
+package buff
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/felixangell/phi/internal/lex"
+	"github.com/felixangell/phi/pkg/diff"
+	"github.com/felixangell/phi/pkg/piecetable"
+)
+
+// recordDiskInfo remembers the mod time and size of the file as
+// it is on disk, so we can tell if it's changed before saving.
+func (b *Buffer) recordDiskInfo() {
+	info, err := os.Stat(b.filePath)
+	if err != nil {
+		b.diskModTime, b.diskSize = time.Time{}, -1
+		return
+	}
+	b.diskModTime, b.diskSize = info.ModTime(), info.Size()
+}
+
+// changedOnDisk returns if the file has been changed by
+// something else since we loaded or last saved it.
+func (b *Buffer) changedOnDisk() bool {
+	info, err := os.Stat(b.filePath)
+	if err != nil {
+		// it's been deleted or we can't see it, either
+		// way there's nothing for us to overwrite.
+		return false
+	}
+
+	if info.ModTime().Equal(b.diskModTime) && info.Size() == b.diskSize {
+		return false
+	}
+
+	// it may have only been touched, so we check the
+	// contents if we have a hash for them.
+	if b.diskHash != "" && info.Size() == b.diskSize {
+		hash, err := hashFile(b.filePath)
+		if err == nil && hash == b.diskHash {
+			b.diskModTime = info.ModTime()
+			return false
+		}
+	}
+	return true
+}
+
+// showConflict asks what to do about the file being
+// changed on disk when we try to save over it.
+func (b *Buffer) showConflict() {
+	b.showError("%s has changed on disk since it was opened", b.filePath)
+
+	v := b.parent
+	v.UnfocusBuffers()
+	v.focusPalette(b)
+
+	p := v.commandPalette
+	p.buff.setLine(0, "! conflict ")
+	p.calculateSuggestions()
+}
+
+func (p *CommandPalette) calculateConflictSuggestions() {
+	p.recentSuggestions = &[]suggestion{
+		{p, "! conflict overwrite"},
+		{p, "! conflict merge"},
+		{p, "! conflict reload"},
+		{p, "! conflict diff"},
+	}
+}
+
+// ResolveConflict deals with the file being changed on disk by
+// something else, we can either overwrite it with the buffer,
+// merge the changes into the buffer, reload the buffer from disk
+// or see what the differences are.
+func ResolveConflict(v *BufferView, commands []*lex.Token) BufferDirtyState {
+	if len(commands) == 0 {
+		return false
+	}
+
+	b := v.getCurrentBuff()
+	if b == nil {
+		return false
+	}
+
+	switch commands[0].Lexeme {
+	case "overwrite":
+		b.save(true)
+
+	case "merge":
+		b.mergeFromDisk()
+
+	case "reload":
+		// this throws away the changes in the buffer
+		b.modified = false
+		b.reload()
+		b.clampCursor()
+
+	case "diff":
+		b.showDiff()
+
+	default:
+		log.Println("conflict expects overwrite, merge, reload or diff but got", commands[0].Lexeme)
+		return false
+	}
+
+	return true
+}
+
+// how many lines are shown around each change in a diff
+const diffContext = 3
+
+// showDiff opens a new buffer with the differences between
+// the file on disk and the buffer.
+func (b *Buffer) showDiff() {
+	onDisk, _, err := b.readDisk()
+	if err != nil {
+		b.showError("Failed to read %s: %s", b.filePath, err.Error())
+		return
+	}
+
+	a := onDisk.String()
+	text := diff.Unified(b.filePath+" (on disk)", b.filePath+" (buffer)",
+		strings.Split(a, "\n"), strings.Split(b.table.String(), "\n"), diffContext)
+
+	diffBuff := b.parent.AddBuffer()
+	diffBuff.table = piecetable.MakePieceTable(text)
+}
+
+// readDisk reads the file as it is on disk into a table, decoded
+// the same way the buffer was. the raw contents are also returned.
+func (b *Buffer) readDisk() (*piecetable.PieceTable, []byte, error) {
+	contents, err := ioutil.ReadFile(b.filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	onDisk := string(contents)
+	if b.encoding.enc != nil {
+		decoded, err := b.encoding.enc.NewDecoder().Bytes(contents)
+		if err != nil {
+			return nil, nil, err
+		}
+		onDisk = string(decoded)
+	}
+	return piecetable.MakePieceTable(onDisk), contents, nil
+}
@@ -0,0 +1,35 @@
+package buff
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedOnDiskMixedLineEndings(t *testing.T) {
+	f, err := ioutil.TempFile("", "phi-mixed-")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	f.WriteString("first\r\nsecond\nthird\r\n")
+	f.Close()
+
+	b := &Buffer{filePath: f.Name()}
+	assert.NoError(t, b.loadTable(f.Name(), nil))
+	assert.True(t, b.table.MixedLineEndings())
+	assert.False(t, b.changedOnDisk())
+
+	// only touching the file doesn't change it
+	later := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(f.Name(), later, later))
+	assert.False(t, b.changedOnDisk())
+
+	// but changing it for something the same size does
+	assert.NoError(t, ioutil.WriteFile(f.Name(), []byte("first\r\nsecond\nthirD\r\n"), 0644))
+	later = later.Add(time.Hour)
+	assert.NoError(t, os.Chtimes(f.Name(), later, later))
+	assert.True(t, b.changedOnDisk())
+}
@@ -25,14 +25,52 @@ func newCursor(parent *Buffer) *Cursor {
 	}
 }
 
-// SetPos will set the cursor position
-// FIXME this should update the rx, ry:
-// there are two ways to do this (as far as I know):
-// - re-process the line somehow?
-// - 'simulate' what steps to move the cursor from old pos to new pos.
-// using the move() func which updates rx, ry for us.
+// SetPos will set the cursor position. this 'simulates'
+// the steps to move the cursor to the new line so that the
+// ry and the camera are updated for us, the column is then
+// worked out in one go as stepping along a long line is slow.
 func (c *Cursor) SetPos(x, y int) {
-	// STUB!
+	b := c.parent
+
+	b.gotoLine(int64(y + 1))
+
+	x = min(x, b.table.LineLen(c.y))
+	if x < 0 {
+		x = 0
+	}
+
+	line := b.table.Line(c.y)
+	c.moveRender(x-c.x, 0, renderWidth(line, x)-renderWidth(line, c.x), 0)
+}
+
+// renderWidth returns how wide the first n characters of the
+// line are when rendered, the same as moveRight steps over them.
+func renderWidth(line string, n int) int {
+	width := 0
+	for _, r := range line {
+		if n == 0 {
+			break
+		}
+		width++
+		if r == '\t' {
+			width += 3
+		}
+		n--
+	}
+	return width + n
+}
+
+// clampCursor moves the cursor back into the buffer
+// if the line or column it was on no longer exists.
+func (b *Buffer) clampCursor() {
+	// SetPos steps up from where the cursor is, which would look
+	// at lines that aren't there any more if it's past the end, so
+	// it's put on the last line first.
+	if last := b.table.LineCount() - 1; b.curs.y > last {
+		b.curs.moveRender(0, last-b.curs.y, 0, last-b.curs.y)
+		b.cam.dy = min(b.cam.dy, last)
+	}
+	b.curs.SetPos(b.curs.x, b.curs.y)
 }
 
 func (c *Cursor) SetSize(w, h int) {
@@ -0,0 +1,196 @@
+package buff
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/felixangell/phi/internal/lex"
+	"github.com/felixangell/phi/pkg/piecetable"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// textEncoding is an encoding a file can be stored in, the
+// piece table is always utf-8 so anything else is decoded
+// when the file is opened and encoded again when it is saved.
+type textEncoding struct {
+	name string
+
+	// nil for plain utf-8
+	enc encoding.Encoding
+}
+
+// files are saved with a byte order mark
+// only if they were opened with one.
+var (
+	utf8Encoding       = &textEncoding{"utf-8", nil}
+	utf8BOMEncoding    = &textEncoding{"utf-8bom", unicode.UTF8BOM}
+	utf16LEEncoding    = &textEncoding{"utf-16le", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)}
+	utf16BEEncoding    = &textEncoding{"utf-16be", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)}
+	utf16LEBOMEncoding = &textEncoding{"utf-16lebom", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)}
+	utf16BEBOMEncoding = &textEncoding{"utf-16bebom", unicode.UTF16(unicode.BigEndian, unicode.UseBOM)}
+	latin1Encoding     = &textEncoding{"latin-1", charmap.ISO8859_1}
+	cp1252Encoding     = &textEncoding{"windows-1252", charmap.Windows1252}
+)
+
+var textEncodings = map[string]*textEncoding{}
+
+func init() {
+	for _, enc := range []*textEncoding{
+		utf8Encoding, utf8BOMEncoding,
+		utf16LEEncoding, utf16BEEncoding,
+		utf16LEBOMEncoding, utf16BEBOMEncoding,
+		latin1Encoding, cp1252Encoding,
+	} {
+		textEncodings[enc.name] = enc
+	}
+}
+
+// how much of the file we look at to guess the encoding
+const sniffSize = 4096
+
+// detectEncoding guesses the encoding of a file from
+// the first few bytes of it.
+func detectEncoding(head []byte, truncated bool) *textEncoding {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return utf8BOMEncoding
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return utf16LEBOMEncoding
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return utf16BEBOMEncoding
+	}
+
+	// without a bom we can still spot utf-16 as most text
+	// is ascii, so every other byte will be zero.
+	var even, odd int
+	for idx, c := range head {
+		if c != 0 {
+			continue
+		}
+		if idx%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	if pairs := len(head) / 2; pairs > 0 {
+		switch {
+		case odd > pairs/2 && even == 0:
+			return utf16LEEncoding
+		case even > pairs/2 && odd == 0:
+			return utf16BEEncoding
+		}
+	}
+
+	// we may have cut a character in half
+	// at the end of what we read in.
+	if truncated {
+		for idx := 0; idx < utf8.UTFMax-1 && len(head) > 0 && !utf8.Valid(head); idx++ {
+			head = head[:len(head)-1]
+		}
+	}
+	if utf8.Valid(head) {
+		return utf8Encoding
+	}
+
+	// windows-1252 is latin-1 with printable characters in
+	// place of the control codes at 0x80 to 0x9f, these are
+	// never used in latin-1 text so if they are here its
+	// most likely from windows.
+	for _, c := range head {
+		if c >= 0x80 && c <= 0x9f {
+			return cp1252Encoding
+		}
+	}
+	return latin1Encoding
+}
+
+func sniffEncoding(filePath string) (*textEncoding, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return detectEncoding(head[:n], n == sniffSize), nil
+}
+
+// decodeFile reads the file in and decodes it from
+// the given encoding into a new piece table.
+func decodeFile(filePath string, enc *textEncoding) (*piecetable.PieceTable, []byte, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoded, err := enc.enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return piecetable.MakePieceTable(string(decoded)), data, nil
+}
+
+// SetEncoding changes the encoding of the buffer. the file
+// can be re-opened in the encoding if it was guessed wrong,
+// e.g. "! encoding reopen windows-1252", or saved in it with
+// "! encoding save utf-16le".
+func SetEncoding(v *BufferView, commands []*lex.Token) BufferDirtyState {
+	b := v.getCurrentBuff()
+	if b == nil || b.table.ReadOnly() {
+		return false
+	}
+
+	if len(commands) < 2 {
+		b.showError("encoding expects reopen or save and the name of the encoding")
+		return false
+	}
+
+	name := strings.ToLower(commands[1].Lexeme)
+	enc, ok := textEncodings[name]
+	if !ok {
+		b.showError("Unknown encoding %s", name)
+		return false
+	}
+
+	switch commands[0].Lexeme {
+	case "reopen":
+		if b.modified {
+			b.showError("Can't reopen %s as it has unsaved changes", b.filePath)
+			return false
+		}
+		if err := b.loadTable(b.filePath, enc); err != nil {
+			b.showError("Failed to reopen %s as %s: %s", b.filePath, name, err.Error())
+			return false
+		}
+		b.curs.SetPos(0, 0)
+		b.modified = false
+
+	case "save":
+		// the buffer keeps its old encoding
+		// if the file couldn't be written.
+		prevEncoding, prevModified := b.encoding, b.modified
+		b.encoding = enc
+		b.modified = true
+		if !b.save(false) {
+			b.encoding, b.modified = prevEncoding, prevModified
+			return false
+		}
+
+	default:
+		b.showError("encoding expects reopen or save but got %s", commands[0].Lexeme)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,119 @@
+package buff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/felixangell/phi/internal/cfg"
+)
+
+// the undo history for each file is kept in the config
+// directory, named after the hash of the files path. the
+// file content hash is stored before the history so we can
+// tell if the file has been changed outside of the editor.
+
+func hashContents(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile hashes the file as it is on disk. this isn't the
+// same as hashing the table, the table has one line ending
+// where the file may have had a mix of them.
+func hashFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// configFilePath returns the path of the file for the given
+// file in the directory dir of the config directory.
+func configFilePath(dir, filePath string) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(absPath))
+	return filepath.Join(cfg.ConfigDir(), dir, hex.EncodeToString(sum[:])), nil
+}
+
+func historyPath(filePath string) (string, error) {
+	return configFilePath("history", filePath)
+}
+
+// saveHistory writes the undo history out for the
+// version of the file that is on disk.
+func (b *Buffer) saveHistory() {
+	if b.filePath == "" || b.diskHash == "" {
+		return
+	}
+
+	path, err := historyPath(b.filePath)
+	if err != nil {
+		log.Println("Failed to save undo history", err.Error())
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Println("Failed to save undo history", err.Error())
+		return
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Println("Failed to save undo history", err.Error())
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(b.diskHash + "\n"); err != nil {
+		log.Println("Failed to save undo history", err.Error())
+		return
+	}
+	if err := b.table.WriteHistory(f, b.diskState); err != nil {
+		log.Println("Failed to save undo history", err.Error())
+	}
+}
+
+// loadHistory restores the undo history for the file
+// if it was saved for what is currently on disk.
+func (b *Buffer) loadHistory() {
+	if b.diskHash == "" {
+		return
+	}
+
+	path, err := historyPath(b.filePath)
+	if err != nil {
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	hash := make([]byte, len(b.diskHash)+1)
+	if _, err := io.ReadFull(f, hash); err != nil || string(hash) != b.diskHash+"\n" {
+		log.Println("File has changed since the undo history was saved, discarding it")
+		return
+	}
+
+	if err := b.table.ReadHistory(f); err != nil {
+		log.Println("Failed to load undo history", err.Error())
+		return
+	}
+	b.diskState = b.table.CurrentState()
+}
@@ -0,0 +1,375 @@
+package buff
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixangell/phi/internal/cfg"
+	"github.com/felixangell/phi/internal/lex"
+	"github.com/felixangell/phi/pkg/piecetable"
+)
+
+// unsaved changes are written to a journal in the recovery
+// directory every so often, so they aren't lost if phi crashes.
+// the journal is named the same way as the undo history, and
+// is a header line followed by the contents of the buffer.
+//
+// any journals found when phi starts are moved into the leftover
+// directory, otherwise opening and editing the same file would
+// write over the changes before they can be recovered.
+
+// how often the journal is written while
+// the buffer has unsaved changes.
+const journalInterval = 5 * time.Second
+
+type journalHeader struct {
+	Path     string    `json:"path"`
+	Encoding string    `json:"encoding"`
+	Time     time.Time `json:"time"`
+}
+
+type journal struct {
+	// the version of the table that was last written
+	// and when, these are only used on the main thread.
+	version uint64
+	written time.Time
+	exists  bool
+
+	// bumped on every write or removal, a write that is
+	// overtaken by another is dropped rather than written.
+	mu  sync.Mutex
+	seq uint64
+}
+
+func journalDir() string {
+	return filepath.Join(cfg.ConfigDir(), "recovery")
+}
+
+func leftoverDir() string {
+	return filepath.Join(journalDir(), "leftover")
+}
+
+func journalPath(filePath string) (string, error) {
+	return configFilePath("recovery", filePath)
+}
+
+// journalContents writes the header line and then
+// the document for saveFile.
+type journalContents struct {
+	header []byte
+	snap   *piecetable.Snapshot
+}
+
+func (j *journalContents) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(j.header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := j.snap.WriteTo(w)
+	return int64(n) + m, err
+}
+
+// updateJournal writes the journal if there are changes
+// that haven't been journalled yet, or removes it once
+// they have been saved.
+func (b *Buffer) updateJournal() {
+	j := &b.journal
+	if b.filePath == "" || time.Since(j.written) < journalInterval {
+		return
+	}
+
+	if !b.modified {
+		if j.exists {
+			b.removeJournal()
+		}
+		return
+	}
+
+	if b.table.Version() != j.version && !b.table.ReadOnly() {
+		b.writeJournal(false)
+	}
+}
+
+// writeJournal writes the buffer out to its journal from another
+// goroutine, or on this one if wait is set.
+func (b *Buffer) writeJournal(wait bool) {
+	path, err := journalPath(b.filePath)
+	if err != nil {
+		log.Println("Failed to write journal", err.Error())
+		return
+	}
+
+	header, err := json.Marshal(journalHeader{watchPath(b.filePath), b.encoding.name, time.Now()})
+	if err != nil {
+		log.Println("Failed to write journal", err.Error())
+		return
+	}
+
+	j := &b.journal
+	snap := b.table.Snapshot()
+	j.version, j.written, j.exists = snap.Version(), time.Now(), true
+
+	j.mu.Lock()
+	j.seq++
+	seq := j.seq
+	j.mu.Unlock()
+
+	write := func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if j.seq != seq {
+			return
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			log.Println("Failed to write journal", err.Error())
+			return
+		}
+		contents := &journalContents{append(header, '\n'), snap}
+		if err := saveFile(path, contents, nil, true); err != nil {
+			log.Println("Failed to write journal", err.Error())
+		}
+	}
+
+	if wait {
+		write()
+	} else {
+		go write()
+	}
+}
+
+func (b *Buffer) removeJournal() {
+	if b.filePath == "" {
+		return
+	}
+
+	path, err := journalPath(b.filePath)
+	if err != nil {
+		return
+	}
+
+	j := &b.journal
+	j.exists = false
+
+	j.mu.Lock()
+	j.seq++
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Println("Failed to remove journal", err.Error())
+	}
+	j.mu.Unlock()
+}
+
+// leftoverJournal is a journal left behind by phi
+// exiting before the changes in it were saved.
+type leftoverJournal struct {
+	header journalHeader
+	path   string
+}
+
+func readJournalHeader(path string) (journalHeader, *bufio.Reader, *os.File, error) {
+	var header journalHeader
+
+	f, err := os.Open(path)
+	if err != nil {
+		return header, nil, nil, err
+	}
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &header)
+	}
+	if err != nil {
+		f.Close()
+		return header, nil, nil, err
+	}
+	return header, r, f, nil
+}
+
+// moveLeftoverJournals moves the journals in the recovery
+// directory into the leftover directory. they're given new
+// names as there may already be one there for the same file.
+func moveLeftoverJournals() {
+	entries, err := ioutil.ReadDir(journalDir())
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		if err := os.MkdirAll(leftoverDir(), 0700); err != nil {
+			log.Println("Failed to move journal", err.Error())
+			return
+		}
+
+		from := filepath.Join(journalDir(), entry.Name())
+		if err := os.Rename(from, genFileName(leftoverDir(), entry.Name()+".", "")); err != nil {
+			log.Println("Failed to move journal", from, err.Error())
+		}
+	}
+}
+
+func findJournals() []leftoverJournal {
+	moveLeftoverJournals()
+
+	entries, err := ioutil.ReadDir(leftoverDir())
+	if err != nil {
+		return nil
+	}
+
+	var journals []leftoverJournal
+	for _, entry := range entries {
+		// ignore any temp files from a write that didn't finish
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(leftoverDir(), entry.Name())
+		header, _, f, err := readJournalHeader(path)
+		if err != nil {
+			log.Println("Failed to read journal", path, err.Error())
+			continue
+		}
+		f.Close()
+
+		journals = append(journals, leftoverJournal{header, path})
+	}
+	return journals
+}
+
+// OfferRecovery looks for any changes that weren't saved the
+// last time phi was run, and offers to restore them if there are.
+func (n *BufferView) OfferRecovery() {
+	n.journals = findJournals()
+	n.showRecovery()
+}
+
+func (n *BufferView) showRecovery() {
+	b := n.getCurrentBuff()
+	if b == nil || len(n.journals) == 0 {
+		return
+	}
+
+	b.showMessage("Found unsaved changes to %d files from before", len(n.journals))
+
+	n.UnfocusBuffers()
+	n.focusPalette(b)
+
+	p := n.commandPalette
+	p.buff.setLine(0, "! recover ")
+	p.calculateSuggestions()
+}
+
+func (p *CommandPalette) calculateRecoverSuggestions() {
+	journals := p.parent.journals
+
+	suggestions := make([]suggestion, 0, len(journals)+1)
+	for _, j := range journals {
+		name := fmt.Sprintf("! recover \"%s\" (%s)", j.header.Path, j.header.Time.Format("2006-01-02 15:04:05"))
+		suggestions = append(suggestions, suggestion{p, name})
+	}
+	suggestions = append(suggestions, suggestion{p, "! recover discard"})
+
+	p.recentSuggestions = &suggestions
+}
+
+// Recover restores the unsaved changes to the given file that
+// were left over from before, or discards all of them.
+func Recover(v *BufferView, commands []*lex.Token) BufferDirtyState {
+	if len(commands) == 0 {
+		return false
+	}
+
+	arg := commands[0]
+	switch {
+	case arg.IsType(lex.String):
+		// strip out the quotes (1...n-1)
+		v.recoverJournal(arg.Lexeme[1 : len(arg.Lexeme)-1])
+
+	case arg.Lexeme == "discard":
+		for _, j := range v.journals {
+			os.Remove(j.path)
+		}
+		v.journals = nil
+
+	default:
+		log.Println("recover expects a file or discard but got", arg.Lexeme)
+		return false
+	}
+
+	return true
+}
+
+func (n *BufferView) recoverJournal(filePath string) {
+	idx := -1
+	for i, j := range n.journals {
+		if j.header.Path == filePath {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		log.Println("No journal to recover for", filePath)
+		return
+	}
+
+	header, r, f, err := readJournalHeader(n.journals[idx].path)
+	if err != nil {
+		log.Println("Failed to read journal", err.Error())
+		return
+	}
+	contents, err := ioutil.ReadAll(r)
+	f.Close()
+	if err != nil {
+		log.Println("Failed to read journal", err.Error())
+		return
+	}
+
+	b, ok := n.bufferMap[watchPath(filePath)]
+	if !ok {
+		b = n.AddBuffer()
+		b.OpenFile(filePath)
+	}
+
+	if b.table.ReadOnly() {
+		b.showError("Can't recover changes to %s while it is still loading", filePath)
+		return
+	}
+
+	if enc, ok := textEncodings[header.Encoding]; ok {
+		b.encoding = enc
+	}
+
+	// done as an edit so it can be undone back to what's on disk
+	text := piecetable.MakePieceTable(string(contents)).String()
+	b.setLines(strings.Split(text, "\n"))
+	b.modified = true
+
+	// the buffer has its own journal from here on, it's
+	// written before the leftover one is thrown away.
+	b.writeJournal(true)
+	if err := os.Remove(n.journals[idx].path); err != nil {
+		log.Println("Failed to remove journal", err.Error())
+	}
+
+	n.journals = append(n.journals[:idx], n.journals[idx+1:]...)
+
+	recovered := fmt.Sprintf("Recovered unsaved changes to %s from %s", filePath, header.Time.Format("2006-01-02 15:04:05"))
+	if len(n.journals) > 0 {
+		b.showMessage("%s, %d more can be recovered with ! recover", recovered, len(n.journals))
+	} else {
+		b.showMessage("%s", recovered)
+	}
+}
@@ -0,0 +1,41 @@
+package buff
+
+import (
+	"log"
+	"strings"
+
+	"github.com/felixangell/phi/internal/lex"
+	"github.com/felixangell/phi/pkg/piecetable"
+)
+
+var lineEndingNames = map[string]piecetable.LineEnding{
+	"lf":   piecetable.LF,
+	"crlf": piecetable.CRLF,
+	"cr":   piecetable.CR,
+}
+
+// SetLineEnding converts the buffer to the given line
+// ending, e.g. "! line_ending crlf". the file is written
+// out with it the next time it is saved.
+func SetLineEnding(v *BufferView, commands []*lex.Token) BufferDirtyState {
+	if len(commands) == 0 {
+		return false
+	}
+
+	b := v.getCurrentBuff()
+	if b == nil || b.table.ReadOnly() {
+		return false
+	}
+
+	ending, ok := lineEndingNames[strings.ToLower(commands[0].Lexeme)]
+	if !ok {
+		log.Println("line_ending expects one of lf, crlf or cr but got", commands[0].Lexeme)
+		return false
+	}
+
+	if ending != b.table.LineEnding() {
+		b.table.SetLineEnding(ending)
+		b.modified = true
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package buff
+
+import (
+	"strings"
+
+	"github.com/felixangell/phi/pkg/diff"
+	"github.com/felixangell/phi/pkg/piecetable"
+)
+
+// mergeFromDisk merges the changes made to the file on disk into
+// the buffer, without losing any of the changes that haven't been
+// saved. the base is the document as it was last loaded or saved,
+// anything changed differently on both sides is left in the buffer
+// between conflict markers for the user to sort out.
+func (b *Buffer) mergeFromDisk() {
+	if b.base == nil || b.table.ReadOnly() {
+		b.showError("Can't merge changes to %s while it is still loading", b.filePath)
+		return
+	}
+
+	onDisk, contents, err := b.readDisk()
+	if err != nil {
+		b.showError("Failed to read %s: %s", b.filePath, err.Error())
+		return
+	}
+
+	theirs := strings.Split(onDisk.String(), "\n")
+	merged, conflicts := diff.Merge3(
+		strings.Split(b.base.String(), "\n"),
+		strings.Split(b.table.String(), "\n"),
+		theirs,
+		"buffer", "disk")
+
+	b.setLines(merged)
+
+	// the file on disk is what we merge against next time.
+	b.base = onDisk.Snapshot()
+	b.recordDiskInfo()
+
+	if conflicts > 0 {
+		b.showError("%d conflicts merging changes to %s from disk", conflicts, b.filePath)
+	} else {
+		b.showMessage("Merged changes to %s from disk", b.filePath)
+	}
+
+	if conflicts == 0 && strings.Join(merged, "\n") == onDisk.String() {
+		// there was nothing unsaved left over.
+		b.diskHash, b.diskState = hashContents(contents), b.table.CurrentState()
+		b.saveMark = b.table.EditMark()
+		b.modified = false
+	} else {
+		// none of the undo states match the file on disk,
+		// so the history isn't kept until the next save.
+		b.diskHash = ""
+		b.modified = true
+	}
+}
+
+// setLines changes the buffer to the given lines, only the
+// lines that differ are replaced and it is done as one undo step.
+func (b *Buffer) setLines(lines []string) {
+	current := strings.Split(b.table.String(), "\n")
+	hunks := diff.Hunks(diff.Lines(current, lines))
+	if len(hunks) == 0 {
+		return
+	}
+
+	b.endRun()
+	b.table.BeginGroup()
+	defer b.table.EndGroup()
+
+	// from the bottom up so the line numbers
+	// of the other hunks stay the same.
+	for idx := len(hunks) - 1; idx >= 0; idx-- {
+		h := hunks[idx]
+		b.replaceLines(h.AStart, h.AEnd, lines[h.BStart:h.BEnd])
+	}
+
+	b.clampCursor()
+}
+
+// replaceLines replaces the lines from start up to end
+// with the given lines.
+func (b *Buffer) replaceLines(start, end int, lines []string) {
+	count := b.table.LineCount()
+	text := strings.Join(lines, "\n")
+
+	from, to := piecetable.Pos{Line: start}, piecetable.Pos{Line: end}
+	if end < count {
+		if len(lines) > 0 {
+			text += "\n"
+		}
+	} else {
+		// there is no line after these, so it's the
+		// newline before them that has to change.
+		to = piecetable.Pos{Line: count - 1, Col: b.table.LineLen(count - 1)}
+		switch {
+		case start == count:
+			from = to
+			text = "\n" + text
+		case len(lines) == 0 && start > 0:
+			from = piecetable.Pos{Line: start - 1, Col: b.table.LineLen(start - 1)}
+		}
+	}
+
+	b.table.DeleteRange(from, to)
+	b.table.InsertAt(from, text)
+}
@@ -25,6 +25,10 @@ type CommandPalette struct {
 
 	suggestionIndex   int
 	recentSuggestions *[]suggestion
+
+	// the theme from before we started previewing
+	// themes, it's put back if none are picked.
+	themeBefore *cfg.ThemeConfig
 }
 
 func (p *CommandPalette) SetFocus(focus bool) {
@@ -79,8 +83,12 @@ func (s *suggestion) render(x, y int, ctx *strife.Renderer) {
 }
 
 func NewCommandPalette(conf cfg.PhiEditorConfig, view *BufferView) *CommandPalette {
-	conf.Editor.ShowLineNumbers = false
-	conf.Editor.HighlightLine = false
+	// the editor config is copied too so this
+	// doesn't change it for the other buffers.
+	editor := *conf.Editor
+	editor.ShowLineNumbers = false
+	editor.HighlightLine = false
+	conf.Editor = &editor
 
 	newSize := int(float64(conf.Editor.FontSize) * cfg.ScaleFactor)
 	paletteFont, err := gui.GetDefaultFont().DeriveFont(newSize)
@@ -89,23 +97,9 @@ func NewCommandPalette(conf cfg.PhiEditorConfig, view *BufferView) *CommandPalet
 	}
 
 	palette := &CommandPalette{
-		conf:   &conf,
-		parent: view,
-		buff: NewBuffer(&conf, BufferConfig{
-			conf.Theme.Palette.Background,
-			conf.Theme.Palette.Foreground,
-
-			conf.Theme.Palette.Cursor,
-			conf.Theme.Palette.Cursor, // TODO invert
-
-			conf.Theme.HighlightLineBackground,
-
-			// we dont show line numbers
-			// so these aren't necessary
-			0x0, 0x0,
-
-			paletteFont,
-		}, nil, 0),
+		conf:       &conf,
+		parent:     view,
+		buff:       NewBuffer(&conf, paletteBufferConfig(conf.Theme, paletteFont), nil, 0),
 		parentBuff: nil,
 	}
 	palette.buff.appendLine("")
@@ -137,8 +131,26 @@ func NewCommandPalette(conf cfg.PhiEditorConfig, view *BufferView) *CommandPalet
 	return palette
 }
 
+func paletteBufferConfig(theme *cfg.ThemeConfig, font *strife.Font) BufferConfig {
+	return BufferConfig{
+		theme.Palette.Background,
+		theme.Palette.Foreground,
+
+		theme.Palette.Cursor,
+		theme.Palette.Cursor, // TODO invert
+
+		theme.HighlightLineBackground,
+
+		// we dont show line numbers
+		// so these aren't necessary
+		0x0, 0x0,
+
+		font,
+	}
+}
+
 func (b *CommandPalette) processCommand() {
-	input := b.buff.table.Lines[0].String()
+	input := b.buff.table.Line(0)
 	tokens := lex.New(input).Tokenize()
 
 	if len(tokens) <= 1 {
@@ -158,7 +170,7 @@ func (b *CommandPalette) processCommand() {
 }
 
 func (b *CommandPalette) calculateCommandSuggestions() {
-	input := b.buff.table.Lines[0].String()
+	input := b.buff.table.Line(0)
 	input = strings.TrimSpace(input)
 
 	tokenizedLine := strings.Split(input, " ")
@@ -188,6 +200,26 @@ func (b *CommandPalette) calculateCommandSuggestions() {
 		return
 	}
 
+	if command == "undo_goto" {
+		b.calculateUndoSuggestions()
+		return
+	}
+
+	if command == "conflict" {
+		b.calculateConflictSuggestions()
+		return
+	}
+
+	if command == "recover" {
+		b.calculateRecoverSuggestions()
+		return
+	}
+
+	if command == "theme" {
+		b.calculateThemeSuggestions(strings.Join(tokenizedLine[1:], " "))
+		return
+	}
+
 	ranks := fuzzy.RankFind(command, commandSet)
 	var suggestions []suggestion
 
@@ -204,7 +236,7 @@ func (b *CommandPalette) calculateCommandSuggestions() {
 }
 
 func (b *CommandPalette) calculateSuggestions() {
-	input := b.buff.table.Lines[0].String()
+	input := b.buff.table.Line(0)
 	input = strings.TrimSpace(input)
 
 	if len(input) == 0 {
@@ -285,9 +317,11 @@ func (b *CommandPalette) OnUpdate() bool {
 
 		case sdl.K_UP:
 			b.scrollSuggestion(-1)
+			b.previewTheme()
 			return false
 		case sdl.K_DOWN:
 			b.scrollSuggestion(1)
+			b.previewTheme()
 			return false
 
 		// any other key we calculate
@@ -1,25 +1,44 @@
 package buff
 
-import "github.com/felixangell/phi/internal/lex"
+import (
+	"github.com/felixangell/phi/internal/cfg"
+	"github.com/felixangell/phi/internal/lex"
+)
 
 var register = map[string]BufferAction{
 	"page_down":    NewBufferAction("page_down", pageDown),
 	"page_up":      NewBufferAction("page_up", pageUp),
 	"undo":         NewBufferAction("undo", Undo),
 	"redo":         NewBufferAction("redo", Redo),
+	"undo_goto":    NewBufferAction("undo_goto", UndoGoto),
+	"undo_tree":    NewBufferAction("undo_tree", ShowUndoTree),
 	"focus_left":   NewBufferAction("focus_left", focusLeft),
 	"focus_right":  NewBufferAction("focus_right", focusRight),
 	"goto":         NewBufferAction("goto", GotoLine),
 	"new":          NewBufferAction("new", NewFile),
 	"open":         NewBufferAction("open", OpenFile),
 	"save":         NewBufferAction("save", Save),
+	"conflict":     NewBufferAction("conflict", ResolveConflict),
+	"recover":      NewBufferAction("recover", Recover),
+	"theme":        NewBufferAction("theme", SetTheme),
 	"delete_line":  NewBufferAction("delete_line", DeleteLine),
+	"encoding":     NewBufferAction("encoding", SetEncoding),
+	"line_ending":  NewBufferAction("line_ending", SetLineEnding),
 	"close_buffer": NewBufferAction("close_buffer", CloseBuffer),
 	"paste":        NewBufferAction("paste", Paste),
 	"show_palette": NewBufferAction("show_palette", ShowPalette),
 	"exit":         NewBufferAction("exit", ExitPhi),
 }
 
+func init() {
+	// so the config can check the commands it has shortcuts for
+	names := make([]string, 0, len(register))
+	for name := range register {
+		names = append(names, name)
+	}
+	cfg.RegisterCommands(names...)
+}
+
 func ExecuteCommandIfExist(command string, view *BufferView, tokens ...*lex.Token) BufferDirtyState {
 	if cmd, ok := register[command]; ok {
 		return cmd.proc(view, tokens)
@@ -0,0 +1,118 @@
+package buff
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// the permission bits we carry over from the
+// file we are replacing when saving atomically.
+const keptModeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// saveFile writes the contents of src to the file at the given
+// path, encoding it unless enc is nil. the bytes that end up in
+// the file are also written to the extra writers.
+//
+// if the path is a symlink the file it points to is written to.
+// when saving atomically the file is written next to the target
+// and renamed over it once it's on disk, so the target is never
+// left half written. the mode and owner of the target are copied
+// over to the new file where we can.
+func saveFile(filePath string, src io.WriterTo, enc encoding.Encoding, atomic bool, extra ...io.Writer) error {
+	target, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		target = filePath
+	}
+
+	info, err := os.Stat(target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if info != nil {
+		mode = info.Mode() & keptModeBits
+	}
+
+	// writing in place keeps the mode and owner
+	// as it is the same file.
+	if !atomic {
+		return writeFile(target, mode, src, enc, extra...)
+	}
+
+	dir := filepath.Dir(target)
+	tempPath := genFileName(dir, "."+filepath.Base(target)+".", ".tmp")
+
+	if err := writeFile(tempPath, mode, src, enc, extra...); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	if info != nil {
+		// we can only give the file away if we are allowed
+		// to, otherwise it's owned by us. this has to happen
+		// before the chmod as it clears the setuid bits.
+		copyOwner(tempPath, info)
+
+		// the mode the file was created with is masked by
+		// the umask, so it's set explicitly here.
+		if err := os.Chmod(tempPath, mode); err != nil {
+			os.Remove(tempPath)
+			return err
+		}
+	}
+
+	if err := os.Rename(tempPath, target); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	// the rename isn't on disk until the directory is, but
+	// the file has been replaced so the save has still worked.
+	if err := syncDir(dir); err != nil {
+		log.Println("Failed to sync", dir, "after saving", target, err.Error())
+	}
+	return nil
+}
+
+// writeFile writes src to the file at the given path and
+// syncs it to disk before closing it.
+func writeFile(filePath string, mode os.FileMode, src io.WriterTo, enc encoding.Encoding, extra ...io.Writer) error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	var dst io.Writer = io.MultiWriter(append([]io.Writer{f}, extra...)...)
+
+	var encoder io.WriteCloser
+	if enc != nil {
+		encoder = transform.NewWriter(dst, enc.NewEncoder())
+		dst = encoder
+	}
+
+	w := bufio.NewWriter(dst)
+	_, err = src.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil && encoder != nil {
+		err = encoder.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
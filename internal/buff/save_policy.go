@@ -0,0 +1,83 @@
+package buff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/felixangell/phi/internal/cfg"
+	"github.com/felixangell/phi/pkg/piecetable"
+)
+
+// applySavePolicies tidies up the buffer as it's configured
+// to before it is saved. all of the changes are made as one
+// undo step, so they can be undone in one go after the save.
+func (b *Buffer) applySavePolicies() {
+	conf := b.cfg.Editor
+
+	b.endRun()
+	b.table.BeginGroup()
+	defer b.table.EndGroup()
+
+	var lines []int
+	switch conf.TrimTrailingWhitespace {
+	case cfg.TrimAll:
+		for idx := 0; idx < b.table.LineCount(); idx++ {
+			lines = append(lines, idx)
+		}
+	case cfg.TrimModified:
+		lines = b.table.EditedLines(b.saveMark)
+	}
+	for _, idx := range lines {
+		b.trimTrailingWhitespace(idx)
+	}
+
+	if conf.CollapseTrailingBlankLines {
+		b.collapseTrailingBlankLines()
+	}
+
+	if conf.EnforceNewlineAtEOF {
+		last := b.table.LineCount() - 1
+		if lineLen := b.table.LineLen(last); lineLen > 0 {
+			b.table.InsertAt(piecetable.Pos{Line: last, Col: lineLen}, "\n")
+		}
+	}
+
+	b.clampCursor()
+}
+
+func (b *Buffer) trimTrailingWhitespace(idx int) {
+	line := b.table.Line(idx)
+	trimmed := strings.TrimRightFunc(line, unicode.IsSpace)
+	if len(trimmed) == len(line) {
+		return
+	}
+
+	b.table.DeleteRange(
+		piecetable.Pos{Line: idx, Col: utf8.RuneCountInString(trimmed)},
+		piecetable.Pos{Line: idx, Col: b.table.LineLen(idx)},
+	)
+}
+
+// collapseTrailingBlankLines removes all of the blank lines at
+// the end of the buffer, apart from the one that comes after
+// the final newline.
+func (b *Buffer) collapseTrailingBlankLines() {
+	last := b.table.LineCount() - 1
+
+	lastText := last
+	for lastText >= 0 && strings.TrimSpace(b.table.Line(lastText)) == "" {
+		lastText--
+	}
+
+	// there's nothing after the last line of text, or
+	// there is just the line after the final newline
+	if lastText == last || (lastText+1 == last && b.table.LineLen(last) == 0) {
+		return
+	}
+
+	b.table.DeleteRange(
+		piecetable.Pos{Line: lastText + 1, Col: 0},
+		piecetable.Pos{Line: last, Col: b.table.LineLen(last)},
+	)
+}
@@ -0,0 +1,14 @@
+package buff
+
+import "os"
+
+// files on windows don't have an owner we can copy
+// over, and directories can't be synced.
+
+func copyOwner(path string, info os.FileInfo) error {
+	return nil
+}
+
+func syncDir(dir string) error {
+	return nil
+}
@@ -1,12 +1,10 @@
 package buff
 
 import (
-	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
-	"io/ioutil"
 	"log"
-	"os"
 	"path/filepath"
 
 	"github.com/atotto/clipboard"
@@ -22,15 +20,14 @@ func ShowPalette(v *BufferView, _ []*lex.Token) BufferDirtyState {
 
 func Paste(v *BufferView, _ []*lex.Token) BufferDirtyState {
 	b := v.getCurrentBuff()
-	if b == nil {
+	if b == nil || b.table.ReadOnly() {
 		return false
 	}
 
 	str, err := clipboard.ReadAll()
 
 	if err == nil {
-		b.insertString(b.curs.x, str)
-		b.moveToEndOfLine()
+		b.insertString(str)
 		return true
 	}
 
@@ -43,9 +40,11 @@ func Undo(v *BufferView, _ []*lex.Token) BufferDirtyState {
 	if b == nil {
 		return false
 	}
+	b.endRun()
 	node := b.table.Undo()
 	if node != nil {
-		b.curs.SetPos(node.Start, node.Index)
+		b.modified = true
+		b.curs.SetPos(b.table.RuneCol(node.Index, node.Start), node.Index)
 	}
 	return false
 }
@@ -55,9 +54,11 @@ func Redo(v *BufferView, _ []*lex.Token) BufferDirtyState {
 	if b == nil {
 		return false
 	}
+	b.endRun()
 	node := b.table.Redo()
 	if node != nil {
-		b.curs.SetPos(node.Start, node.Index)
+		b.modified = true
+		b.curs.SetPos(b.table.RuneCol(node.Index, node.Start), node.Index)
 	}
 	return false
 }
@@ -77,73 +78,67 @@ func genFileName(dir, prefix, suffix string) string {
 // re-rendered.
 
 func Save(v *BufferView, _ []*lex.Token) BufferDirtyState {
-	// TODO Config option for this.
-	atomicFileSave := true
-
 	b := v.getCurrentBuff()
 	if b == nil {
 		return false
 	}
+	b.save(false)
+	return false
+}
 
-	var buffer bytes.Buffer
-	for idx, line := range b.table.Lines {
-		if idx > 0 {
-			// TODO: this avoids a trailing newline
-			// if we handle it like this? but if we have
-			// say enforce_newline_at_eof or something we
-			// might want to do this all the time
-			buffer.WriteRune('\n')
-		}
-		buffer.WriteString(line.String())
+// save writes the buffer out to its file. unless forced we
+// wont overwrite the file if it has been changed on disk.
+func (b *Buffer) save(force bool) bool {
+	// we only have part of the file, saving
+	// now would cut the rest of it off.
+	if b.table.ReadOnly() {
+		b.showError("Can't save %s while it is still loading", b.filePath)
+		return false
 	}
 
+	if !force && b.changedOnDisk() {
+		b.showConflict()
+		return false
+	}
+
+	// the policies are undone if the save fails
+	// so the buffer isn't left changed for nothing.
+	beforePolicies := b.table.CurrentState()
+	b.applySavePolicies()
+
 	// TODO:
 	// - files probably dont have to be entirely
 	//   re-saved all the time!
-	// - we can probably stream this somehow?
 	// - multi threaded?
-	// - lots of checks to do here: does the file exist/not exist
-	//   handle the errors... etc.
-
-	// ALWAYS use atomic save possibly?
-	// start writing the file on a gothread
-	// and then move the file overwriting when the
-	// file has been written?
-
-	filePath := b.filePath
-	if atomicFileSave {
-		absPath, err := filepath.Abs(filePath)
-		if err != nil {
-			log.Println("cant get abs dir of path ", b.filePath, " can't save!")
-			return false
-		}
 
-		ext := filepath.Ext(absPath)
-		dir := filepath.Dir(absPath)
+	// the document is streamed straight from the piece
+	// table into the file, hashing it as we go.
+	hash := sha256.New()
 
-		filePath = genFileName(dir, "", ext)
-	}
-
-	err := ioutil.WriteFile(filePath, buffer.Bytes(), 0644)
-	if err != nil {
-		log.Println(err.Error())
+	if err := saveFile(b.filePath, b.table, b.encoding.enc, b.cfg.Editor.AtomicSave, hash); err != nil {
+		b.showError("Failed to save %s: %s", b.filePath, err.Error())
+		if b.table.CurrentState() != beforePolicies {
+			b.table.Undo()
+			b.clampCursor()
+		}
+		return false
 	}
 
 	log.Println("Wrote file '" + b.filePath + "' to disk")
-	if atomicFileSave {
-		log.Println("- Wrote atomically to file:", filePath)
-	}
 
-	if atomicFileSave {
-		log.Println("- Over-writing atomic file save")
-
-		err := os.Rename(filePath, b.filePath)
-		if err != nil {
-			log.Println("Failed to save!", err.Error())
-			return false
-		}
+	if b.table.MixedLineEndings() {
+		ending := b.table.LineEnding()
+		b.showMessage("Saved the mixed line endings in %s as %s", b.filePath, ending)
+		b.table.SetLineEnding(ending)
 	}
 
+	b.diskHash, b.diskState = hex.EncodeToString(hash.Sum(nil)), b.table.CurrentState()
+	b.saveMark = b.table.EditMark()
+	b.base = b.table.Snapshot()
+	b.fileState = fileOnDisk
+	b.recordDiskInfo()
+	b.saveHistory()
+
 	b.modified = false
-	return false
+	return true
 }
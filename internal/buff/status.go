@@ -0,0 +1,38 @@
+package buff
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// how long a message is shown in
+// the meta panel for.
+const statusTimeout = 5 * time.Second
+
+// statusMessage is a message shown in place
+// of the file info in the meta panel.
+type statusMessage struct {
+	text    string
+	isError bool
+	shown   time.Time
+}
+
+func (b *Buffer) showMessage(format string, args ...interface{}) {
+	b.status = statusMessage{fmt.Sprintf(format, args...), false, time.Now()}
+	log.Println(b.status.text)
+}
+
+func (b *Buffer) showError(format string, args ...interface{}) {
+	b.status = statusMessage{fmt.Sprintf(format, args...), true, time.Now()}
+	log.Println(b.status.text)
+}
+
+// currentStatus returns the message to show in the
+// meta panel if there is one.
+func (b *Buffer) currentStatus() (statusMessage, bool) {
+	if b.status.text == "" || time.Now().Sub(b.status.shown) > statusTimeout {
+		return statusMessage{}, false
+	}
+	return b.status, true
+}
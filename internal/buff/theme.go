@@ -0,0 +1,118 @@
+package buff
+
+import (
+	"log"
+	"strings"
+
+	"github.com/felixangell/phi/internal/cfg"
+	"github.com/felixangell/phi/internal/lex"
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// the start of the suggestions for the theme command
+const themePrefix = "! theme "
+
+func (p *CommandPalette) calculateThemeSuggestions(input string) {
+	names := cfg.ThemeNames()
+	if input != "" {
+		ranks := fuzzy.RankFind(input, names)
+		ranked := make([]string, 0, len(ranks))
+		for _, r := range ranks {
+			ranked = append(ranked, r.Target)
+		}
+		names = ranked
+	}
+
+	suggestions := make([]suggestion, len(names))
+	for idx, name := range names {
+		suggestions[idx] = suggestion{p, themePrefix + name}
+	}
+	p.recentSuggestions = &suggestions
+}
+
+// previewTheme shows the theme that is currently
+// suggested, without picking it.
+func (p *CommandPalette) previewTheme() {
+	if p.recentSuggestions == nil || p.suggestionIndex < 0 || p.suggestionIndex >= len(*p.recentSuggestions) {
+		return
+	}
+
+	sugg := (*p.recentSuggestions)[p.suggestionIndex]
+	if !strings.HasPrefix(sugg.name, themePrefix) {
+		return
+	}
+
+	// a theme that has problems is still shown, the
+	// problems are reported when it's picked.
+	theme, err := cfg.LoadTheme(strings.TrimPrefix(sugg.name, themePrefix))
+	if theme == nil {
+		log.Println("Failed to preview theme:", err.Error())
+		return
+	}
+
+	if p.themeBefore == nil {
+		before := *p.parent.conf.Theme
+		p.themeBefore = &before
+	}
+	p.parent.applyTheme(theme)
+}
+
+// endThemePreview puts back the theme from before
+// we started previewing if one wasn't picked.
+func (p *CommandPalette) endThemePreview() {
+	if p.themeBefore == nil {
+		return
+	}
+	p.parent.applyTheme(p.themeBefore)
+	p.themeBefore = nil
+}
+
+// applyTheme changes the colours of everything to the theme.
+func (n *BufferView) applyTheme(theme *cfg.ThemeConfig) {
+	// the palette and buffers share the
+	// theme with the view so this is seen by all.
+	*n.conf.Theme = *theme
+
+	// the highlighted lines have the old colours
+	syntaxCache = map[string]syntaxInfo{}
+
+	buffOpts := n.bufferConfig()
+	for _, buffPane := range n.buffers {
+		buffPane.Buff.buffOpts = buffOpts
+	}
+
+	p := n.commandPalette
+	p.buff.buffOpts = paletteBufferConfig(theme, p.buff.buffOpts.font)
+}
+
+// SetTheme changes to the named theme. this is only for the
+// session, the theme in the editor config is used next time.
+func SetTheme(v *BufferView, commands []*lex.Token) BufferDirtyState {
+	if len(commands) == 0 {
+		log.Println("theme expects the name of a theme")
+		return false
+	}
+
+	name := commands[0].Lexeme
+	theme, err := cfg.LoadTheme(name)
+	if theme == nil {
+		if b := v.getCurrentBuff(); b != nil {
+			b.showError("Failed to load theme %s: %s", name, err.Error())
+		}
+		return false
+	}
+
+	// this one is kept rather than put back
+	v.commandPalette.themeBefore = nil
+	v.conf.Editor.Theme = name
+	v.applyTheme(theme)
+
+	if b := v.getCurrentBuff(); b != nil {
+		if err != nil {
+			b.showError("Theme %s has problems: %s", name, err.Error())
+		} else {
+			b.showMessage("Changed to the %s theme", name)
+		}
+	}
+	return true
+}
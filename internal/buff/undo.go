@@ -0,0 +1,89 @@
+package buff
+
+import (
+	"time"
+	"unicode"
+)
+
+// how long we can pause typing for before
+// the next edit starts a new undo step.
+const undoRunTimeout = time.Second
+
+type editKind int
+
+const (
+	noEdit editKind = iota
+	typingEdit
+	deletingEdit
+)
+
+// undoRun is a run of characters typed (or deleted) one
+// after the other, these are coalesced into a single undo
+// step rather than having to undo each character.
+type undoRun struct {
+	kind editKind
+	last time.Time
+
+	// where the cursor was left after the last edit,
+	// if it moves we start a new run.
+	x, y int
+
+	lastRune rune
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// continueRun is called before a typed character is inserted
+// or deleted. it groups the edit with the previous edits, or
+// starts a new group if it is a different kind of edit, the
+// cursor has jumped, we've paused for a while, or we've
+// started a new word.
+func (b *Buffer) continueRun(kind editKind, r rune) {
+	run := &b.undoRun
+
+	if run.kind != noEdit {
+		jumped := b.curs.x != run.x || b.curs.y != run.y
+		paused := time.Now().Sub(run.last) > undoRunTimeout
+		newWord := isWordRune(r) && !isWordRune(run.lastRune)
+
+		if run.kind != kind || jumped || paused || newWord {
+			b.endRun()
+		}
+	}
+
+	if run.kind == noEdit {
+		b.table.BeginGroup()
+		run.kind = kind
+	}
+	run.lastRune = r
+}
+
+// markRun is called after the edit, the next edit has
+// to start where this one left the cursor to be part
+// of the same run.
+func (b *Buffer) markRun() {
+	b.undoRun.x, b.undoRun.y = b.curs.x, b.curs.y
+	b.undoRun.last = time.Now()
+}
+
+// endRun ends the current run, this must be called
+// before any other kind of edit is made otherwise it
+// would be grouped with the run.
+func (b *Buffer) endRun() {
+	if b.undoRun.kind == noEdit {
+		return
+	}
+	b.table.EndGroup()
+	b.undoRun = undoRun{}
+}
+
+// runeBeforeCursor returns the character a backspace
+// would delete.
+func (b *Buffer) runeBeforeCursor() rune {
+	if b.curs.x == 0 {
+		return '\n'
+	}
+	return b.table.Index(b.curs.y, b.curs.x-1)
+}
@@ -0,0 +1,124 @@
+package buff
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/felixangell/phi/internal/lex"
+)
+
+// the layouts we accept when jumping to
+// a point in time in the undo tree.
+var undoTimeLayouts = []string{
+	"15:04:05",
+	"15:04",
+	"2006-01-02 15:04:05",
+}
+
+func parseUndoTime(value string) (time.Time, bool) {
+	now := time.Now()
+	for _, layout := range undoTimeLayouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+
+		// just a time of day, so we assume today.
+		if t.Year() == 0 {
+			t = time.Date(now.Year(), now.Month(), now.Day(),
+				t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// UndoGoto moves the buffer to a state in the undo tree,
+// either by its sequence number or a "time" string.
+func UndoGoto(v *BufferView, commands []*lex.Token) BufferDirtyState {
+	if len(commands) == 0 {
+		return false
+	}
+
+	b := v.getCurrentBuff()
+	if b == nil {
+		return false
+	}
+
+	b.endRun()
+
+	arg := commands[0]
+	switch {
+	case arg.IsType(lex.Number):
+		seq, err := strconv.Atoi(arg.Lexeme)
+		if err != nil {
+			log.Println("undo_goto invalid argument ", err.Error())
+			return false
+		}
+		b.setUndoState(b.table.GotoState(seq) != nil)
+
+	case arg.IsType(lex.String):
+		// strip out the quotes (1...n-1)
+		value := arg.Lexeme[1 : len(arg.Lexeme)-1]
+		t, ok := parseUndoTime(value)
+		if !ok {
+			log.Println("undo_goto can't parse time", value)
+			return false
+		}
+		b.setUndoState(b.table.GotoTime(t) != nil)
+	}
+
+	return true
+}
+
+func (b *Buffer) setUndoState(changed bool) {
+	if !changed {
+		return
+	}
+	b.modified = true
+	b.clampCursor()
+}
+
+// ShowUndoTree opens the palette with the branches
+// of the undo tree as suggestions to jump to.
+func ShowUndoTree(v *BufferView, _ []*lex.Token) BufferDirtyState {
+	b := v.getCurrentBuff()
+	if b == nil {
+		return false
+	}
+
+	v.UnfocusBuffers()
+	v.focusPalette(b)
+
+	p := v.commandPalette
+	p.buff.setLine(0, "! undo_goto ")
+	p.calculateSuggestions()
+	return true
+}
+
+func (p *CommandPalette) calculateUndoSuggestions() {
+	b := p.parentBuff
+	if b == nil {
+		p.recentSuggestions = nil
+		return
+	}
+
+	current := b.table.CurrentState()
+
+	// most recent branch first
+	branches := b.table.UndoBranches()
+	suggestions := make([]suggestion, 0, len(branches))
+	for idx := len(branches) - 1; idx >= 0; idx-- {
+		state := branches[idx]
+
+		name := fmt.Sprintf("! undo_goto %d (%s, %d changes)", state.Seq, state.Time.Format("15:04:05"), state.Changes)
+		if state.Seq == current {
+			name += " *"
+		}
+		suggestions = append(suggestions, suggestion{p, name})
+	}
+
+	p.recentSuggestions = &suggestions
+}
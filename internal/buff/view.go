@@ -5,28 +5,41 @@ import (
 	"github.com/felixangell/phi/internal/cfg"
 	"github.com/felixangell/phi/internal/command_handler"
 	"github.com/felixangell/phi/internal/gui"
+	"github.com/felixangell/phi/pkg/events"
 	"github.com/felixangell/strife"
 	"github.com/fsnotify/fsnotify"
 	"github.com/veandco/go-sdl2/sdl"
 	"log"
+	"path/filepath"
+	"time"
 )
 
+// how long a file has to go quiet after it has been
+// changed on disk before we reload it.
+const reloadDelay = 100 * time.Millisecond
+
 type bufferEvent interface {
 	Process(view *BufferView)
 	String() string
 }
 
-type reloadBufferEvent struct {
-	buff *Buffer
+// diskChangeEvent is sent when a file has been
+// changed on disk, op is every change in the burst.
+type diskChangeEvent struct {
+	path string
+	op   fsnotify.Op
 }
 
-func (r *reloadBufferEvent) Process(view *BufferView) {
-	log.Println("reloading buffer", r.buff.filePath)
-	r.buff.reload()
+func (d *diskChangeEvent) Process(view *BufferView) {
+	buff, ok := view.bufferMap[d.path]
+	if !ok {
+		return
+	}
+	buff.diskChanged(d.op)
 }
 
-func (r *reloadBufferEvent) String() string {
-	return "reload-buffer-event"
+func (d *diskChangeEvent) String() string {
+	return "disk-change-event"
 }
 
 // View is an array of buffers basically.
@@ -38,19 +51,35 @@ type BufferView struct {
 	focusedBuff    int
 	commandPalette *CommandPalette
 
-	watcher      *fsnotify.Watcher
-	bufferMap    map[string]*Buffer
-	bufferEvents chan bufferEvent
+	watcher   *fsnotify.Watcher
+	bufferMap map[string]*Buffer
+
+	// the directories of the files we are watching, we watch
+	// these too to see files being replaced or renamed.
+	watchedDirs map[string]int
+
+	// the config directories that are being watched
+	configWatches map[string]bool
+
+	// events from the watcher are debounced and then queued
+	// up, they are handled on the main thread in OnUpdate.
+	debouncer    *events.Debouncer
+	bufferEvents events.Queue
+
+	// journals left over from before that can be recovered
+	journals []leftoverJournal
 }
 
 // NewView creaets a new view with the given width and height
 // as well as configurations.
 func NewView(width, height int, conf *cfg.PhiEditorConfig) *BufferView {
 	view := &BufferView{
-		conf:         conf,
-		buffers:      []*BufferPane{},
-		bufferMap:    map[string]*Buffer{},
-		bufferEvents: make(chan bufferEvent),
+		conf:      conf,
+		buffers:   []*BufferPane{},
+		bufferMap: map[string]*Buffer{},
+
+		watchedDirs:   map[string]int{},
+		configWatches: map[string]bool{},
 	}
 
 	view.Translate(width, height)
@@ -68,60 +97,133 @@ func NewView(width, height int, conf *cfg.PhiEditorConfig) *BufferView {
 		// ?
 	}
 
+	view.debouncer = events.NewDebouncer(reloadDelay, func(path string, op uint32) {
+		if path == configChangeKey {
+			view.bufferEvents.Push(&configChangeEvent{})
+			return
+		}
+		view.bufferEvents.Push(&diskChangeEvent{path, fsnotify.Op(op)})
+	})
+	view.watchConfig()
+
 	// goroutine to handle all of the fsnotify events
 	// converts them into events phi can handle cleanly.
+	// nothing else in the view is touched from here.
 	go func() {
 		for {
 			select {
-			case event := <-view.watcher.Events:
+			case event, ok := <-view.watcher.Events:
+				if !ok {
+					return
+				}
 				log.Println("evt: ", event)
-				if event.Op&fsnotify.Write == fsnotify.Write {
+				view.debouncer.Trigger(event.Name, uint32(event.Op))
 
-					// modified so we specify a reload event
-					buff, ok := view.bufferMap[event.Name]
-					if !ok {
-						break
-					}
-
-					view.bufferEvents <- &reloadBufferEvent{buff}
-					log.Println("modified file:", event.Name)
+				// all of the config files are reloaded
+				// together, so this is one event.
+				if isConfigPath(event.Name) {
+					view.debouncer.Trigger(configChangeKey, 0)
+				}
+			case err, ok := <-view.watcher.Errors:
+				if !ok {
+					return
 				}
-			case err := <-view.watcher.Errors:
 				log.Println("error:", err)
 			}
 		}
 	}()
 
-	// handles all of the phi events
-	go func() {
-		for {
-			event := <-view.bufferEvents
-			event.Process(view)
-		}
-	}()
-
 	return view
 }
 
+// watchPath returns the path we watch the given file by,
+// this is what the names of the watchers events are.
+func watchPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
 func (n *BufferView) registerFile(path string, buff *Buffer) {
 	log.Println("Registering file ", path)
 
-	err := n.watcher.Add(path)
-	if err != nil {
-		log.Println(fmt.Sprintf("Failed to register file '%s'", path), "to buffer ", buff.index)
+	path = watchPath(path)
+	n.bufferMap[path] = buff
+
+	// saving by renaming a new file over the old one (which
+	// we do too) loses the watch on the file, so we watch the
+	// directory as well to see it being put back.
+	dir := filepath.Dir(path)
+	if err := n.watchDir(dir); err != nil {
+		log.Println(fmt.Sprintf("Failed to watch directory '%s'", dir), err.Error())
+	}
+
+	n.watchFile(path)
+}
+
+func (n *BufferView) unregisterFile(path string, buff *Buffer) {
+	path = watchPath(path)
+	if n.bufferMap[path] != buff {
 		return
 	}
+	delete(n.bufferMap, path)
 
-	n.bufferMap[path] = buff
+	n.watcher.Remove(path)
+	n.unwatchDir(filepath.Dir(path))
+}
+
+// watchDir adds a watch on the directory, these are counted
+// so the watch is only removed once nothing needs it.
+func (n *BufferView) watchDir(dir string) error {
+	if n.watchedDirs[dir] == 0 {
+		if err := n.watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+	n.watchedDirs[dir]++
+	return nil
+}
+
+func (n *BufferView) unwatchDir(dir string) {
+	if n.watchedDirs[dir] == 0 {
+		return
+	}
+	n.watchedDirs[dir]--
+	if n.watchedDirs[dir] == 0 {
+		delete(n.watchedDirs, dir)
+		n.watcher.Remove(dir)
+	}
+}
+
+// watchFile adds a watch on the file itself, this has to be
+// done again whenever the file is replaced.
+func (n *BufferView) watchFile(path string) {
+	if err := n.watcher.Add(path); err != nil {
+		log.Println(fmt.Sprintf("Failed to register file '%s'", path), err.Error())
+	}
 }
 
 // Close will close the view and all of the components
 func (n *BufferView) Close() {
+	n.debouncer.Stop()
 	n.watcher.Close()
 }
 
+// processEvents handles all of the events that
+// have been queued up since the last update.
+func (n *BufferView) processEvents() {
+	for _, event := range n.bufferEvents.Drain() {
+		e := event.(bufferEvent)
+		log.Println("processing", e.String())
+		e.Process(n)
+	}
+}
+
 func (n *BufferView) hidePalette() {
 	p := n.commandPalette
+	p.endThemePreview()
 	p.clearInput()
 	p.SetFocus(false)
 
@@ -254,6 +356,12 @@ func (n *BufferView) OnInit() {
 
 // OnUpdate ...
 func (n *BufferView) OnUpdate() bool {
+	n.processEvents()
+
+	for _, buffPane := range n.buffers {
+		buffPane.Buff.updateJournal()
+	}
+
 	controlDown = strife.KeyPressed(sdl.K_LCTRL) || strife.KeyPressed(sdl.K_RCTRL)
 	superDown = strife.KeyPressed(sdl.K_LGUI) || strife.KeyPressed(sdl.K_RGUI)
 
@@ -323,14 +431,11 @@ func (n *BufferView) OnRender(ctx *strife.Renderer) {
 // OnDispose ...
 func (n *BufferView) OnDispose() {}
 
-// AddBuffer will unfocus all of the buffers
-// and insert a new buffer. Focus is given to this
-// new buffer, which is then returned from this function.
-func (n *BufferView) AddBuffer() *Buffer {
-	n.UnfocusBuffers()
-
+// bufferConfig returns the options for the
+// buffers in this view from the config.
+func (n *BufferView) bufferConfig() BufferConfig {
 	conf := n.conf
-	c := NewBuffer(conf, BufferConfig{
+	return BufferConfig{
 		conf.Theme.Background,
 		conf.Theme.Foreground,
 		conf.Theme.Cursor,
@@ -339,7 +444,16 @@ func (n *BufferView) AddBuffer() *Buffer {
 		conf.Theme.GutterBackground,
 		conf.Theme.GutterForeground,
 		gui.GetDefaultFont(),
-	}, n, len(n.buffers))
+	}
+}
+
+// AddBuffer will unfocus all of the buffers
+// and insert a new buffer. Focus is given to this
+// new buffer, which is then returned from this function.
+func (n *BufferView) AddBuffer() *Buffer {
+	n.UnfocusBuffers()
+
+	c := NewBuffer(n.conf, n.bufferConfig(), n, len(n.buffers))
 
 	c.SetFocus(true)
 
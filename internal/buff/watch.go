@@ -0,0 +1,63 @@
+package buff
+
+import (
+	"log"
+	"os"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileState is what has happened to the
+// file the buffer is for on disk.
+type fileState int
+
+const (
+	fileOnDisk fileState = iota
+	fileDeleted
+	fileMoved
+)
+
+func (f fileState) String() string {
+	switch f {
+	case fileDeleted:
+		return "deleted"
+	case fileMoved:
+		return "moved"
+	}
+	return "on disk"
+}
+
+// diskChanged handles the buffers file being changed on disk,
+// op is all of the changes the watcher saw in one go.
+func (b *Buffer) diskChanged(op fsnotify.Op) {
+	if _, err := os.Stat(b.filePath); err != nil {
+		// the buffer is left as it is, saving
+		// it will put the file back again.
+		state := fileDeleted
+		if op&fsnotify.Rename != 0 {
+			state = fileMoved
+		}
+		if b.fileState != state {
+			b.fileState = state
+			b.showError("%s has been %s", b.filePath, state)
+		}
+		return
+	}
+
+	// it's been replaced, most likely by something saving it
+	// by renaming a new file over it, so the watch on it is gone.
+	if op&(fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 || b.fileState != fileOnDisk {
+		b.parent.watchFile(watchPath(b.filePath))
+	}
+	b.fileState = fileOnDisk
+
+	// our own saves record the mod time and size they leave
+	// behind, so they aren't seen as a change and are ignored
+	// here, as are touches and permission changes.
+	if !b.changedOnDisk() {
+		return
+	}
+
+	log.Println("reloading buffer", b.filePath)
+	b.reload()
+}
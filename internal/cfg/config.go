@@ -4,42 +4,34 @@ import (
 	"errors"
 	"log"
 	"strconv"
-	"strings"
 )
 
 type PhiEditorConfig struct {
-	Editor               *EditorConfig               `toml:"editor"`
-	Cursor               *CursorConfig               `toml:"cursor"`
-	Render               *RenderConfig               `toml:"render"`
-	Theme                *ThemeConfig                `toml:"theme"`
-	Associations         map[string]FileAssociations `toml:"file_associations"`
-	Commands             map[string]Command          `toml:"commands"`
-	LanguageAssociations map[string]*LanguageSyntaxConfig
+	Editor               *EditorConfig                    `toml:"editor"`
+	Cursor               *CursorConfig                    `toml:"cursor"`
+	Render               *RenderConfig                    `toml:"render"`
+	Theme                *ThemeConfig                     `toml:"theme"`
+	Associations         map[string]FileAssociations      `toml:"file_associations"`
+	Commands             map[string]Command               `toml:"commands"`
+	LanguageAssociations map[string]*LanguageSyntaxConfig `toml:"-"`
+
+	// built from the LanguageAssociations when it's first used
+	syntaxIndex *syntaxIndex
 }
 
 // GetSyntaxConfig returns a pointer to the parsed
 // syntax language file for the given file extension
 // e.g. what syntax def we need for a .cpp file or a .h file
 func (p *PhiEditorConfig) GetSyntaxConfig(ext string) (*LanguageSyntaxConfig, error) {
-	// fixme relationship is wrong way round so for now we have to iterate over keys.. very st upid
-
-	var theKey string
-	for key, assoc := range p.Associations {
-		for _, assocExt := range assoc.Extensions {
-			if strings.Compare(ext, assocExt) == 0 {
-				theKey = key
-			}
-		}
-	}
-
-	if val, ok := p.LanguageAssociations[theKey]; ok {
-		return val, nil
+	if name, ok := p.getSyntaxIndex().byExtension[ext]; ok {
+		return p.LanguageAssociations[name], nil
 	}
 	return nil, errors.New("no language for extension '" + ext + "'")
 }
 
+// FileAssociations adds more extensions to a language
 type FileAssociations struct {
-	Extensions []string
+	Extensions []string `toml:"extensions"`
 }
 
 type SyntaxCriteria struct {
@@ -50,7 +42,7 @@ type SyntaxCriteria struct {
 }
 
 type Command struct {
-	Shortcut string
+	Shortcut string `toml:"shortcut"`
 }
 
 type CursorConfig struct {
@@ -69,9 +61,11 @@ func (c CursorConfig) GetCaretWidth() int {
 		return -1
 	}
 
+	// Validate reports a bad width, we
+	// just draw a block if we get one.
 	value, err := strconv.ParseInt(c.BlockWidth, 10, 32)
 	if err != nil {
-		panic(err)
+		return -1
 	}
 	return int(value)
 }
@@ -86,20 +80,36 @@ type RenderConfig struct {
 	FrameSleepInterval uint32 `toml:"frame_sleep_interval"`
 }
 
-// todo make this more extendable...
-// e.g. .phi-editor/themes with TOML
-// themes in them and we can select
-// the default theme in the EditorConfig
-// instead.
+// ThemeConfig is the colours used for everything, it's
+// either the theme in the config or one of the themes in
+// the themes directory, which is picked in the EditorConfig.
 type ThemeConfig struct {
-	Background              uint32
-	Foreground              uint32
-	Cursor                  uint32
-	CursorInvert            uint32 `toml:"cursor_invert"`
-	Palette                 PaletteConfig
-	GutterBackground        uint32 `toml:"gutter_background"`
-	GutterForeground        uint32 `toml:"gutter_foreground"`
-	HighlightLineBackground uint32 `toml:"highlight_line_background"`
+	Background              uint32        `toml:"background"`
+	Foreground              uint32        `toml:"foreground"`
+	Cursor                  uint32        `toml:"cursor"`
+	CursorInvert            uint32        `toml:"cursor_invert"`
+	Palette                 PaletteConfig `toml:"palette"`
+	GutterBackground        uint32        `toml:"gutter_background"`
+	GutterForeground        uint32        `toml:"gutter_foreground"`
+	HighlightLineBackground uint32        `toml:"highlight_line_background"`
+
+	// colours for the syntax rules with the same name in
+	// every language, e.g. keyword or comment.
+	Syntax map[string]SyntaxColour `toml:"syntax"`
+}
+
+type SyntaxColour struct {
+	Foreground uint32 `toml:"foreground"`
+	Background uint32 `toml:"background"`
+}
+
+// SyntaxColours returns the colours for the named syntax
+// rule, the themes colours are used if it has any for it.
+func (t *ThemeConfig) SyntaxColours(name string, criteria *SyntaxCriteria) (bg uint32, fg uint32) {
+	if colour, ok := t.Syntax[name]; ok {
+		return colour.Background, colour.Foreground
+	}
+	return criteria.Background, criteria.Foreground
 }
 
 type SuggestionConfig struct {
@@ -110,13 +120,13 @@ type SuggestionConfig struct {
 }
 
 type PaletteConfig struct {
-	Background   uint32 `toml:"background"`
-	Foreground   uint32 `toml:"foreground"`
-	Cursor       uint32 `toml:"cursor"`
-	Outline      uint32 `toml:"outline"`
-	RenderShadow bool   `toml:"render_shadow"`
-	ShadowColor  uint32 `toml:"shadow_color"`
-	Suggestion   SuggestionConfig
+	Background   uint32           `toml:"background"`
+	Foreground   uint32           `toml:"foreground"`
+	Cursor       uint32           `toml:"cursor"`
+	Outline      uint32           `toml:"outline"`
+	RenderShadow bool             `toml:"render_shadow"`
+	ShadowColor  uint32           `toml:"shadow_color"`
+	Suggestion   SuggestionConfig `toml:"suggestion"`
 }
 
 type EditorConfig struct {
@@ -130,6 +140,50 @@ type EditorConfig struct {
 	FontFace            string `toml:"font_face"`
 	FontSize            int    `toml:"font_size"`
 	ShowLineNumbers     bool   `toml:"show_line_numbers"`
+
+	// the name of a theme in the themes directory, if it's
+	// empty the theme in this config is used instead.
+	Theme string `toml:"theme"`
+
+	// writes to a temporary file which is renamed over
+	// the file being saved once it has been written.
+	AtomicSave bool `toml:"atomic_save"`
+
+	// these are applied to the buffer when it is saved
+	EnforceNewlineAtEOF        bool   `toml:"enforce_newline_at_eof"`
+	TrimTrailingWhitespace     string `toml:"trim_trailing_whitespace"`
+	CollapseTrailingBlankLines bool   `toml:"collapse_trailing_blank_lines"`
+}
+
+// the values for TrimTrailingWhitespace
+const (
+	TrimNone     = "none"
+	TrimAll      = "all"
+	TrimModified = "modified"
+)
+
+// DefaultTheme returns the theme that phi comes with.
+func DefaultTheme() *ThemeConfig {
+	return &ThemeConfig{
+		Background:   0x002649,
+		Foreground:   0xf2f4f6,
+		Cursor:       0xf2f4f6,
+		CursorInvert: 0xffffff,
+		Palette: PaletteConfig{
+			Background:   0xffffff,
+			Foreground:   0x000000,
+			Cursor:       0xf2f4f6,
+			Outline:      0xebedef,
+			RenderShadow: true,
+			ShadowColor:  0x000000,
+			Suggestion: SuggestionConfig{
+				Background:         0xebedef,
+				Foreground:         0x3a3839,
+				SelectedBackground: 0xc7cdb1,
+				SelectedForeground: 0x3a3839,
+			},
+		},
+	}
 }
 
 func NewDefaultConfig() *PhiEditorConfig {
@@ -155,27 +209,14 @@ func NewDefaultConfig() *PhiEditorConfig {
 			FontFace:            "Go-Mono",
 			FontSize:            20,
 			ShowLineNumbers:     true,
+
+			AtomicSave: true,
+
+			EnforceNewlineAtEOF:        false,
+			TrimTrailingWhitespace:     TrimNone,
+			CollapseTrailingBlankLines: false,
 		},
-		Theme: &ThemeConfig{
-			Background:   0x002649,
-			Foreground:   0xf2f4f6,
-			Cursor:       0xf2f4f6,
-			CursorInvert: 0xffffff,
-			Palette: PaletteConfig{
-				Background:   0xffffff,
-				Foreground:   0x000000,
-				Cursor:       0xf2f4f6,
-				Outline:      0xebedef,
-				RenderShadow: true,
-				ShadowColor:  0x000000,
-				Suggestion: SuggestionConfig{
-					Background:         0xebedef,
-					Foreground:         0x3a3839,
-					SelectedBackground: 0xc7cdb1,
-					SelectedForeground: 0x3a3839,
-				},
-			},
-		},
+		Theme: DefaultTheme(),
 		Cursor: &CursorConfig{
 			FlashRate:  400, // in ms
 			ResetDelay: 400,
@@ -197,17 +238,17 @@ func NewDefaultConfig() *PhiEditorConfig {
 			"close_buffer": {"super+w"},
 			"delete_line":  {"super+d"},
 		},
-		Associations: map[string]FileAssociations{
-			"c":    {Extensions: []string{".c", ".h", ".cc"}},
-			"go":   {Extensions: []string{".go"}},
-			"md":   {Extensions: []string{".md", ".markdown"}},
-			"toml": {Extensions: []string{".toml"}},
-		},
+		Associations: map[string]FileAssociations{},
 		LanguageAssociations: map[string]*LanguageSyntaxConfig{
-			"go":   GoConfig(),
-			"c":    CConfig(),
-			"md":   MarkdownConfig(),
-			"toml": TOMLConfig(),
+			"go":       GoConfig(),
+			"c":        CConfig(),
+			"md":       MarkdownConfig(),
+			"toml":     TOMLConfig(),
+			"python":   PythonConfig(),
+			"rust":     RustConfig(),
+			"yaml":     YAMLConfig(),
+			"protobuf": ProtobufConfig(),
+			"shell":    ShellConfig(),
 		},
 	}
 }
@@ -0,0 +1,17 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDir returns the directory phi keeps its files in. this
+// follows the XDG base directory spec, so it is $XDG_CONFIG_HOME/phi
+// or ~/.config/phi, and the platforms equivalent elsewhere.
+func ConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".phi-editor"
+	}
+	return filepath.Join(dir, "phi")
+}
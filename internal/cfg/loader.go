@@ -0,0 +1,248 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const configFileName = "config.toml"
+
+// ConfigPath returns the path of the users config file.
+func ConfigPath() string {
+	return filepath.Join(ConfigDir(), configFileName)
+}
+
+// SyntaxDir returns the directory that
+// language syntax files are loaded from.
+func SyntaxDir() string {
+	return filepath.Join(ConfigDir(), "syntax")
+}
+
+// ConfigError is an error in the config file, the line
+// and column are 0 if it isn't known where it is.
+type ConfigError struct {
+	Path    string
+	Line    int
+	Col     int
+	Message string
+}
+
+func (c *ConfigError) Error() string {
+	if c.Line == 0 {
+		return fmt.Sprintf("%s: %s", c.Path, c.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", c.Path, c.Line, c.Col, c.Message)
+}
+
+// LoadConfig loads the users config file, writing the defaults
+// out to it if there isn't one yet. the syntax files and the theme
+// picked in the config are loaded after it, then it is validated.
+//
+// if the config file can't be loaded the default config is used
+// instead. a syntax file that can't be loaded is skipped, and any
+// value that is invalid is put back to its default. every problem
+// found along the way is returned together as ConfigErrors.
+func LoadConfig() (*PhiEditorConfig, error) {
+	var errs ConfigErrors
+
+	conf, err := loadConfig(ConfigPath())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, syntaxErr := range conf.LoadSyntaxFiles(SyntaxDir()) {
+		log.Println("Failed to load syntax file:", syntaxErr.Error())
+		errs = append(errs, syntaxErr)
+	}
+
+	if err := conf.applyTheme(); err != nil {
+		if themeErrs, ok := err.(ConfigErrors); ok {
+			errs = append(errs, themeErrs...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, conf.Validate()...)
+	if len(errs) == 0 {
+		return conf, nil
+	}
+	return conf, errs
+}
+
+func loadConfig(path string) (*PhiEditorConfig, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		conf := NewDefaultConfig()
+		if err := WriteConfig(path, conf); err != nil {
+			log.Println("Failed to write default config", err.Error())
+		}
+		return conf, nil
+	}
+
+	conf, err := LoadConfigFile(path)
+	if err != nil {
+		return NewDefaultConfig(), err
+	}
+	return conf, nil
+}
+
+// LoadConfigFile reads the config at the given path, any values
+// that aren't set in it are left as their defaults.
+func LoadConfigFile(path string) (*PhiEditorConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Loading configuration from", path)
+
+	conf := NewDefaultConfig()
+	meta, err := toml.Decode(string(data), conf)
+	if err != nil {
+		return nil, configError(path, data, err)
+	}
+
+	for _, key := range meta.Undecoded() {
+		log.Println("Unknown key in config", key.String())
+	}
+	return conf, nil
+}
+
+// LoadSyntaxFiles loads each .toml file in the directory as a
+// language named after the file, these replace any built in
+// language with the same name. files that can't be loaded are
+// skipped and the errors for them are returned.
+func (p *PhiEditorConfig) LoadSyntaxFiles(dir string) []error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, path := range paths {
+		lang, err := LoadSyntaxFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), ".toml")
+		lang.path = path
+		p.LanguageAssociations[name] = lang
+	}
+
+	p.syntaxIndex = nil
+	return errs
+}
+
+// LoadSyntaxFile reads the language syntax file at the given path.
+func LoadSyntaxFile(path string) (*LanguageSyntaxConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lang := &LanguageSyntaxConfig{}
+	meta, err := toml.Decode(string(data), lang)
+	if err != nil {
+		return nil, configError(path, data, err)
+	}
+
+	for _, key := range meta.Undecoded() {
+		log.Println("Unknown key in", path, key.String())
+	}
+
+	// these are compiled as the file is highlighted,
+	// so we check them now rather than fail then.
+	if _, err := lang.compileShebangs(); err != nil {
+		return nil, &ConfigError{Path: path, Message: err.Error()}
+	}
+	for name, criteria := range lang.Syntax {
+		if criteria.Pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(criteria.Pattern); err != nil {
+			return nil, &ConfigError{Path: path, Message: fmt.Sprintf("bad pattern for %s: %s", name, err.Error())}
+		}
+	}
+	return lang, nil
+}
+
+// values of the wrong type are reported by the decoder
+// with the line and key in the message rather than as a
+// ParseError, e.g. toml: line 2 (last key "editor.tab_size"): ...
+var decodeErrorPattern = regexp.MustCompile(`^toml: line (\d+) \(last key "(.*)"\): (.*)$`)
+
+// the position put at the start of a ParseErrors message
+var errorPrefixPattern = regexp.MustCompile(`^toml: line \d+( \(last key ".*"\))?: `)
+
+// configError turns the error from the toml decoder
+// into a ConfigError with where the error is.
+func configError(path string, data []byte, err error) error {
+	var parseErr toml.ParseError
+	if !errors.As(err, &parseErr) {
+		return decodeError(path, data, err)
+	}
+
+	// the line is worked out from the offset too as it can
+	// be a line out when the error is at the end of one.
+	pos := parseErr.Position
+	line := pos.Line
+	col := 1
+	if pos.Start > 0 && pos.Start <= len(data) {
+		lineStart := bytes.LastIndexByte(data[:pos.Start], '\n') + 1
+		line = bytes.Count(data[:lineStart], []byte("\n")) + 1
+		col = pos.Start - lineStart + 1
+	}
+
+	msg := parseErr.Message
+	if msg == "" {
+		// the decoder puts the position in the
+		// message itself, we don't want it twice.
+		msg = errorPrefixPattern.ReplaceAllString(parseErr.Error(), "")
+	}
+	return &ConfigError{path, line, col, msg}
+}
+
+func decodeError(path string, data []byte, err error) error {
+	match := decodeErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return &ConfigError{Path: path, Message: err.Error()}
+	}
+
+	line, _ := strconv.Atoi(match[1])
+
+	// the column is wherever the key is on the line
+	col := 1
+	lines := strings.Split(string(data), "\n")
+	if line > 0 && line <= len(lines) {
+		key := match[2][strings.LastIndexByte(match[2], '.')+1:]
+		if idx := strings.Index(lines[line-1], key); idx != -1 {
+			col = idx + 1
+		}
+	}
+	return &ConfigError{path, line, col, match[3]}
+}
+
+// WriteConfig writes the config out to the given path.
+func WriteConfig(path string, conf *PhiEditorConfig) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(conf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,83 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestConfig(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "phi-config")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, configFileName)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	return path
+}
+
+func TestLoadConfigMerges(t *testing.T) {
+	path := writeTestConfig(t, `
+[editor]
+tab_size = 8
+
+[theme]
+background = 0x101010
+
+[commands]
+save = { shortcut = "ctrl+s" }
+`)
+
+	conf, err := LoadConfigFile(path)
+	assert.NoError(t, err)
+
+	defaults := NewDefaultConfig()
+
+	assert.Equal(t, 8, conf.Editor.TabSize)
+	assert.Equal(t, defaults.Editor.FontSize, conf.Editor.FontSize)
+	assert.Equal(t, defaults.Editor.TabsAreSpaces, conf.Editor.TabsAreSpaces)
+
+	assert.Equal(t, uint32(0x101010), conf.Theme.Background)
+	assert.Equal(t, defaults.Theme.Palette, conf.Theme.Palette)
+
+	assert.Equal(t, "ctrl+s", conf.Commands["save"].Shortcut)
+	assert.Equal(t, defaults.Commands["undo"], conf.Commands["undo"])
+
+	assert.Equal(t, defaults.Cursor, conf.Cursor)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := writeTestConfig(t, "[editor]\ntab_size = 4\nfont_size = = 2\n")
+
+	_, err := LoadConfigFile(path)
+	confErr, ok := err.(*ConfigError)
+	if assert.True(t, ok, "%v", err) {
+		assert.Equal(t, 3, confErr.Line)
+		assert.Equal(t, 13, confErr.Col)
+	}
+
+	path = writeTestConfig(t, "[editor]\ntab_size = \"four\"\n")
+
+	_, err = LoadConfigFile(path)
+	confErr, ok = err.(*ConfigError)
+	if assert.True(t, ok, "%v", err) {
+		assert.Equal(t, 2, confErr.Line)
+		assert.Equal(t, 1, confErr.Col)
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	path := writeTestConfig(t, "")
+
+	defaults := NewDefaultConfig()
+	assert.NoError(t, WriteConfig(path, defaults))
+
+	conf, err := LoadConfigFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, defaults.Editor, conf.Editor)
+	assert.Equal(t, defaults.Theme, conf.Theme)
+	assert.Equal(t, defaults.Commands, conf.Commands)
+}
@@ -1,11 +1,157 @@
 package cfg
 
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LanguageSyntaxConfig is how a language is highlighted and
+// which files it is used for. files are matched by their name,
+// then their extension, then by the interpreter in their #! line.
 type LanguageSyntaxConfig struct {
+	Extensions []string `toml:"extensions"`
+	Filenames  []string `toml:"filenames"`
+
+	// patterns for the interpreter named in a #! line,
+	// e.g. "python[0-9.]*" matches #!/usr/bin/env python3
+	Shebangs []string `toml:"shebangs"`
+
 	Syntax map[string]*SyntaxCriteria `toml:"syntax"`
+
+	// the file this was loaded from, empty if it's built in
+	path string
+}
+
+// compileShebang compiles the shebang pattern to
+// match the whole of the first line of a file.
+func compileShebang(shebang string) (*regexp.Regexp, error) {
+	patt, err := regexp.Compile(`^#!.*[/\s](?:` + shebang + `)(?:\s|$)`)
+	if err != nil {
+		return nil, fmt.Errorf("bad shebang pattern %q: %s", shebang, err.Error())
+	}
+	return patt, nil
+}
+
+// compileShebangs returns all of the shebang patterns compiled.
+func (l *LanguageSyntaxConfig) compileShebangs() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(l.Shebangs))
+	for _, shebang := range l.Shebangs {
+		patt, err := compileShebang(shebang)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, patt)
+	}
+	return patterns, nil
+}
+
+// source returns where the language came from for errors.
+func (l *LanguageSyntaxConfig) source(name string) string {
+	if l.path == "" {
+		return "the built in " + name + " language"
+	}
+	return l.path
+}
+
+type shebangPattern struct {
+	language string
+	pattern  *regexp.Regexp
+}
+
+// syntaxIndex is for finding the language for a file.
+type syntaxIndex struct {
+	byFilename  map[string]string
+	byExtension map[string]string
+	shebangs    []shebangPattern
+}
+
+func (p *PhiEditorConfig) buildSyntaxIndex() *syntaxIndex {
+	index := &syntaxIndex{
+		byFilename:  map[string]string{},
+		byExtension: map[string]string{},
+	}
+
+	// sorted so it's always the same language that
+	// wins if two of them claim the same extension.
+	names := make([]string, 0, len(p.LanguageAssociations))
+	for name := range p.LanguageAssociations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lang := p.LanguageAssociations[name]
+		for _, filename := range lang.Filenames {
+			if _, ok := index.byFilename[filename]; !ok {
+				index.byFilename[filename] = name
+			}
+		}
+		for _, ext := range lang.Extensions {
+			if _, ok := index.byExtension[ext]; !ok {
+				index.byExtension[ext] = name
+			}
+		}
+
+		// these are checked when the config is loaded but a
+		// broken one is only skipped here, rather than
+		// stopping us from highlighting anything.
+		for _, shebang := range lang.Shebangs {
+			patt, err := compileShebang(shebang)
+			if err != nil {
+				log.Println("Skipping shebang in", lang.source(name), err.Error())
+				continue
+			}
+			index.shebangs = append(index.shebangs, shebangPattern{name, patt})
+		}
+	}
+
+	// the file associations in the config take
+	// priority over what the languages say.
+	for name, assoc := range p.Associations {
+		for _, ext := range assoc.Extensions {
+			index.byExtension[ext] = name
+		}
+	}
+
+	return index
+}
+
+func (p *PhiEditorConfig) getSyntaxIndex() *syntaxIndex {
+	if p.syntaxIndex == nil {
+		p.syntaxIndex = p.buildSyntaxIndex()
+	}
+	return p.syntaxIndex
+}
+
+// SyntaxFor returns the language for the file at the given
+// path, firstLine is the first line of the file for looking
+// at the #! line. nil is returned if there isn't one for it.
+func (p *PhiEditorConfig) SyntaxFor(filePath string, firstLine string) *LanguageSyntaxConfig {
+	index := p.getSyntaxIndex()
+
+	if name, ok := index.byFilename[filepath.Base(filePath)]; ok {
+		return p.LanguageAssociations[name]
+	}
+	if name, ok := index.byExtension[filepath.Ext(filePath)]; ok {
+		return p.LanguageAssociations[name]
+	}
+
+	if strings.HasPrefix(firstLine, "#!") {
+		for _, shebang := range index.shebangs {
+			if shebang.pattern.MatchString(firstLine) {
+				return p.LanguageAssociations[shebang.language]
+			}
+		}
+	}
+	return nil
 }
 
 func MarkdownConfig() *LanguageSyntaxConfig {
-	return &LanguageSyntaxConfig{Syntax: map[string]*SyntaxCriteria{
+	return &LanguageSyntaxConfig{Extensions: []string{".md", ".markdown"}, Syntax: map[string]*SyntaxCriteria{
 		"header": {
 			Foreground: 0xff00ff,
 			Pattern:    "(?m)^#{1,6}.*",
@@ -14,7 +160,7 @@ func MarkdownConfig() *LanguageSyntaxConfig {
 }
 
 func TOMLConfig() *LanguageSyntaxConfig {
-	return &LanguageSyntaxConfig{Syntax: map[string]*SyntaxCriteria{
+	return &LanguageSyntaxConfig{Extensions: []string{".toml"}, Filenames: []string{"Cargo.lock"}, Syntax: map[string]*SyntaxCriteria{
 		"declaration": {
 			Foreground: 0xf8f273,
 			Pattern:    `(\[)(.*)(\])`,
@@ -31,7 +177,7 @@ func TOMLConfig() *LanguageSyntaxConfig {
 }
 
 func CConfig() *LanguageSyntaxConfig {
-	return &LanguageSyntaxConfig{Syntax: map[string]*SyntaxCriteria{
+	return &LanguageSyntaxConfig{Extensions: []string{".c", ".h", ".cc"}, Syntax: map[string]*SyntaxCriteria{
 		"type": {
 			Foreground: 0xf8f273,
 			Match: []string{
@@ -75,7 +221,7 @@ func CConfig() *LanguageSyntaxConfig {
 }
 
 func GoConfig() *LanguageSyntaxConfig {
-	return &LanguageSyntaxConfig{Syntax: map[string]*SyntaxCriteria{
+	return &LanguageSyntaxConfig{Extensions: []string{".go"}, Syntax: map[string]*SyntaxCriteria{
 		"keyword": {
 			Foreground: 0xf0a400,
 			Match: []string{
@@ -115,3 +261,173 @@ func GoConfig() *LanguageSyntaxConfig {
 		},
 	}}
 }
+
+func PythonConfig() *LanguageSyntaxConfig {
+	return &LanguageSyntaxConfig{
+		Extensions: []string{".py", ".pyw", ".pyi"},
+		Filenames:  []string{"SConstruct", "SConscript"},
+		Shebangs:   []string{`python[0-9.]*`},
+		Syntax: map[string]*SyntaxCriteria{
+			"keyword": {
+				Foreground: 0xf0a400,
+				Match: []string{
+					"and", "as", "assert", "async", "await", "break",
+					"class", "continue", "def", "del", "elif", "else",
+					"except", "finally", "for", "from", "global", "if",
+					"import", "in", "is", "lambda", "nonlocal", "not",
+					"or", "pass", "raise", "return", "try", "while",
+					"with", "yield", "None", "True", "False",
+				},
+			},
+			"type": {
+				Foreground: 0xf8f273,
+				Match: []string{
+					"int", "float", "str", "bytes", "bool",
+					"list", "dict", "set", "tuple", "object",
+				},
+			},
+			"comment": {
+				Foreground: 0x4b79fc,
+				Pattern:    `#.*`,
+			},
+			"string_literal": {
+				Foreground: 0x4b79fc,
+				Pattern:    `\"([^\\\"]|\\.)*\"|'([^\\']|\\.)*'`,
+			},
+			"decorator": {
+				Foreground: 0xf8f273,
+				Pattern:    `@\w+`,
+			},
+		},
+	}
+}
+
+func RustConfig() *LanguageSyntaxConfig {
+	return &LanguageSyntaxConfig{
+		Extensions: []string{".rs"},
+		Syntax: map[string]*SyntaxCriteria{
+			"keyword": {
+				Foreground: 0xf0a400,
+				Match: []string{
+					"as", "async", "await", "break", "const", "continue",
+					"crate", "dyn", "else", "enum", "extern", "false",
+					"fn", "for", "if", "impl", "in", "let", "loop",
+					"match", "mod", "move", "mut", "pub", "ref",
+					"return", "self", "Self", "static", "struct",
+					"super", "trait", "true", "type", "unsafe", "use",
+					"where", "while",
+				},
+			},
+			"type": {
+				Foreground: 0xf8f273,
+				Match: []string{
+					"i8", "i16", "i32", "i64", "i128", "isize",
+					"u8", "u16", "u32", "u64", "u128", "usize",
+					"f32", "f64", "bool", "char", "str", "String",
+					"Vec", "Option", "Result", "Box",
+				},
+			},
+			"comment": {
+				Foreground: 0x4b79fc,
+				Pattern:    `//.*`,
+			},
+			"string_literal": {
+				Foreground: 0x4b79fc,
+				Pattern:    `\"([^\\\"]|\\.)*\"`,
+			},
+			"macro": {
+				Foreground: 0xf8f273,
+				Pattern:    `\b\w+!`,
+			},
+		},
+	}
+}
+
+func YAMLConfig() *LanguageSyntaxConfig {
+	return &LanguageSyntaxConfig{
+		Extensions: []string{".yaml", ".yml"},
+		Filenames:  []string{".clang-format"},
+		Syntax: map[string]*SyntaxCriteria{
+			"key": {
+				Foreground: 0xf0a400,
+				Pattern:    `(?m)^\s*(- )?[\w.-]+:`,
+			},
+			"comment": {
+				Foreground: 0x4b79fc,
+				Pattern:    `#.*`,
+			},
+			"string_literal": {
+				Foreground: 0x4b79fc,
+				Pattern:    `\"([^\\\"]|\\.)*\"|'[^']*'`,
+			},
+			"constant": {
+				Foreground: 0xf8f273,
+				Match:      []string{"true", "false", "null", "yes", "no", "~"},
+			},
+		},
+	}
+}
+
+func ProtobufConfig() *LanguageSyntaxConfig {
+	return &LanguageSyntaxConfig{
+		Extensions: []string{".proto"},
+		Syntax: map[string]*SyntaxCriteria{
+			"keyword": {
+				Foreground: 0xf0a400,
+				Match: []string{
+					"syntax", "package", "import", "option", "message",
+					"enum", "service", "rpc", "returns", "stream",
+					"repeated", "optional", "required", "oneof", "map",
+					"reserved", "extend", "public", "weak",
+				},
+			},
+			"type": {
+				Foreground: 0xf8f273,
+				Match: []string{
+					"double", "float", "int32", "int64", "uint32", "uint64",
+					"sint32", "sint64", "fixed32", "fixed64", "sfixed32",
+					"sfixed64", "bool", "string", "bytes",
+				},
+			},
+			"comment": {
+				Foreground: 0x4b79fc,
+				Pattern:    `//.*`,
+			},
+			"string_literal": {
+				Foreground: 0x4b79fc,
+				Pattern:    `\"([^\\\"]|\\.)*\"`,
+			},
+		},
+	}
+}
+
+func ShellConfig() *LanguageSyntaxConfig {
+	return &LanguageSyntaxConfig{
+		Extensions: []string{".sh", ".bash", ".zsh"},
+		Filenames:  []string{".bashrc", ".bash_profile", ".profile", ".zshrc"},
+		Shebangs:   []string{`(ba|z|k|da)?sh`},
+		Syntax: map[string]*SyntaxCriteria{
+			"keyword": {
+				Foreground: 0xf0a400,
+				Match: []string{
+					"if", "then", "else", "elif", "fi", "for", "in",
+					"do", "done", "while", "until", "case", "esac",
+					"function", "return", "local", "export", "set",
+					"unset", "shift", "exit",
+				},
+			},
+			"variable": {
+				Foreground: 0xf8f273,
+				Pattern:    `\$(\{[^}]*\}|\w+|[@*#?$!0-9])`,
+			},
+			"comment": {
+				Foreground: 0x4b79fc,
+				Pattern:    `#.*`,
+			},
+			"string_literal": {
+				Foreground: 0x4b79fc,
+				Pattern:    `\"([^\\\"]|\\.)*\"|'[^']*'`,
+			},
+		},
+	}
+}
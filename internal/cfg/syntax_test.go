@@ -0,0 +1,83 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyntaxFor(t *testing.T) {
+	conf := NewDefaultConfig()
+	langs := conf.LanguageAssociations
+
+	assert.Equal(t, langs["go"], conf.SyntaxFor("/src/main.go", "package main"))
+	assert.Equal(t, langs["python"], conf.SyntaxFor("SConstruct", ""))
+	assert.Equal(t, langs["shell"], conf.SyntaxFor("/home/me/.bashrc", ""))
+
+	// files without an extension go by their #! line
+	assert.Equal(t, langs["python"], conf.SyntaxFor("bin/tool", "#!/usr/bin/env python3"))
+	assert.Equal(t, langs["shell"], conf.SyntaxFor("configure", "#!/bin/sh -e"))
+	assert.Equal(t, langs["shell"], conf.SyntaxFor("run", "#!/usr/bin/env bash"))
+	assert.Nil(t, conf.SyntaxFor("run", "#!/usr/bin/perl"))
+	assert.Nil(t, conf.SyntaxFor("notes", "sh"))
+
+	// the config can point an extension at a language
+	conf.Associations["c"] = FileAssociations{Extensions: []string{".ino"}}
+	conf.syntaxIndex = nil
+	assert.Equal(t, langs["c"], conf.SyntaxFor("sketch.ino", ""))
+
+	// a broken shebang is skipped rather than the whole language
+	langs["python"].Shebangs = append([]string{"("}, langs["python"].Shebangs...)
+	conf.syntaxIndex = nil
+	assert.Equal(t, langs["python"], conf.SyntaxFor("bin/tool", "#!/usr/bin/env python3"))
+}
+
+func TestLoadSyntaxFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phi-syntax")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, contents string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+
+	write("lua.toml", `
+extensions = [".lua"]
+shebangs = ["lua[0-9.]*"]
+
+[syntax.keyword]
+foreground = 0xf0a400
+match = ["local", "function", "end"]
+`)
+	write("go.toml", `
+extensions = [".go"]
+
+[syntax.comment]
+foreground = 0x00ff00
+pattern = "//.*"
+`)
+	write("broken.toml", "extensions = [\".x\"\n")
+	write("badpattern.toml", "[syntax.comment]\npattern = \"(\"\n")
+
+	conf := NewDefaultConfig()
+	errs := conf.LoadSyntaxFiles(dir)
+	assert.Len(t, errs, 2)
+
+	lua := conf.SyntaxFor("init.lua", "")
+	if assert.NotNil(t, lua) {
+		assert.Equal(t, []string{"local", "function", "end"}, lua.Syntax["keyword"].Match)
+	}
+	assert.Equal(t, lua, conf.SyntaxFor("script", "#!/usr/bin/env lua5.3"))
+
+	// replaces the built in go syntax
+	goLang := conf.SyntaxFor("main.go", "")
+	if assert.NotNil(t, goLang) {
+		assert.Len(t, goLang.Syntax, 1)
+		assert.Equal(t, uint32(0x00ff00), goLang.Syntax["comment"].Foreground)
+	}
+
+	assert.Nil(t, conf.SyntaxFor("a.x", ""))
+}
@@ -0,0 +1,109 @@
+package cfg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// the themes that are built into phi, a theme
+// file with the same name replaces them.
+var builtinThemes = map[string]func() *ThemeConfig{
+	"default": DefaultTheme,
+}
+
+// ThemeDir returns the directory that themes are loaded from.
+func ThemeDir() string {
+	return filepath.Join(ConfigDir(), "themes")
+}
+
+// where the themes are looked for, this is
+// changed by the tests to a temp directory.
+var themeDir = ThemeDir
+
+// ThemeNames returns the names of all of the
+// built in themes and the themes in the theme directory.
+func ThemeNames() []string {
+	names := map[string]bool{}
+	for name := range builtinThemes {
+		names[name] = true
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(themeDir(), "*.toml"))
+	for _, path := range paths {
+		names[strings.TrimSuffix(filepath.Base(path), ".toml")] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// LoadTheme loads the theme with the given name from the theme
+// directory, or a built in theme if there is no file for it. any
+// colours that are invalid are put back to the default and are
+// returned as ConfigErrors along with the theme.
+func LoadTheme(name string) (*ThemeConfig, error) {
+	path := filepath.Join(themeDir(), name+".toml")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if builtin, ok := builtinThemes[name]; ok {
+			return builtin(), nil
+		}
+		return nil, fmt.Errorf("there is no theme called %s", name)
+	}
+	return LoadThemeFile(path)
+}
+
+// LoadThemeFile reads the theme at the given path, any colours
+// that aren't set in it are left as the default themes.
+func LoadThemeFile(path string) (*ThemeConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	theme := DefaultTheme()
+	meta, err := toml.Decode(string(data), theme)
+	if err != nil {
+		return nil, configError(path, data, err)
+	}
+
+	for _, key := range meta.Undecoded() {
+		log.Println("Unknown key in", path, key.String())
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".toml")
+	v := &validator{defaults: NewDefaultConfig()}
+	v.validateTheme("themes."+name, theme)
+	if len(v.errs) > 0 {
+		return theme, v.errs
+	}
+	return theme, nil
+}
+
+// applyTheme replaces the theme in the config with
+// the one picked in the editor config, if there is one.
+func (p *PhiEditorConfig) applyTheme() error {
+	if p.Editor == nil || p.Editor.Theme == "" {
+		return nil
+	}
+
+	theme, err := LoadTheme(p.Editor.Theme)
+	if theme == nil {
+		if _, ok := err.(*ConfigError); ok {
+			return err
+		}
+		return &ValidationError{"editor.theme", err.Error()}
+	}
+	p.Theme = theme
+	return err
+}
@@ -0,0 +1,69 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+	themeDir = func() string { return dir }
+	defer func() { themeDir = ThemeDir }()
+
+	write := func(name, contents string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+
+	write("dark.toml", `
+background = 0x101010
+
+[palette]
+outline = 0x202020
+
+[syntax.keyword]
+foreground = 0xff0000
+`)
+	write("broken.toml", "background = 0x1000000\n")
+
+	assert.Equal(t, []string{"broken", "dark", "default"}, ThemeNames())
+
+	theme, err := LoadTheme("dark")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x101010), theme.Background)
+	assert.Equal(t, uint32(0x202020), theme.Palette.Outline)
+	assert.Equal(t, DefaultTheme().Foreground, theme.Foreground)
+
+	keyword := &SyntaxCriteria{Foreground: 0x00ff00}
+	comment := &SyntaxCriteria{Foreground: 0x0000ff}
+	_, fg := theme.SyntaxColours("keyword", keyword)
+	assert.Equal(t, uint32(0xff0000), fg)
+	_, fg = theme.SyntaxColours("comment", comment)
+	assert.Equal(t, uint32(0x0000ff), fg)
+
+	theme, err = LoadTheme("broken")
+	if assert.Error(t, err) {
+		assert.Equal(t, "themes.broken.background", errorKeys(err.(ConfigErrors))[0])
+	}
+	assert.Equal(t, DefaultTheme().Background, theme.Background)
+
+	theme, err = LoadTheme("default")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTheme(), theme)
+
+	_, err = LoadTheme("missing")
+	assert.Error(t, err)
+
+	conf := NewDefaultConfig()
+	conf.Editor.Theme = "dark"
+	assert.NoError(t, conf.applyTheme())
+	assert.Equal(t, uint32(0x101010), conf.Theme.Background)
+
+	conf.Editor.Theme = "missing"
+	err = conf.applyTheme()
+	if assert.IsType(t, &ValidationError{}, err) {
+		assert.Equal(t, "editor.theme", err.(*ValidationError).Key)
+	}
+}
@@ -0,0 +1,292 @@
+package cfg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the largest value a colour can be, 0xRRGGBB
+const maxColour = 0xffffff
+
+// the commands and the keys for shortcuts are defined by the
+// packages that use them, which register them here as this
+// package can't import them.
+var (
+	knownCommands = map[string]bool{}
+	knownKeys     = map[string]bool{}
+)
+
+// RegisterCommands adds the names of commands that
+// can be given shortcuts in the config.
+func RegisterCommands(names ...string) {
+	for _, name := range names {
+		knownCommands[name] = true
+	}
+}
+
+// RegisterShortcutKeys adds the names of
+// the keys that can be used in a shortcut.
+func RegisterShortcutKeys(keys ...string) {
+	for _, key := range keys {
+		knownKeys[key] = true
+	}
+}
+
+// ValidationError is a problem with a value in the config,
+// Key is where it is e.g. "cursor.block_width".
+type ValidationError struct {
+	Key     string
+	Message string
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", v.Key, v.Message)
+}
+
+// ConfigErrors is every problem found with the config.
+type ConfigErrors []error
+
+func (c ConfigErrors) Error() string {
+	switch len(c) {
+	case 0:
+		return "no errors"
+	case 1:
+		return c[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more problems)", c[0].Error(), len(c)-1)
+}
+
+type validator struct {
+	errs     ConfigErrors
+	defaults *PhiEditorConfig
+}
+
+func (v *validator) add(key, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{key, fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the config for anything that doesn't make
+// sense or can't be used, every problem found is returned.
+// the broken values are put back to their defaults, or
+// removed if they don't have one.
+func (p *PhiEditorConfig) Validate() ConfigErrors {
+	v := &validator{defaults: NewDefaultConfig()}
+
+	if p.Editor != nil {
+		v.validateEditor(p.Editor)
+	}
+	if p.Cursor != nil {
+		v.validateCursor(p.Cursor)
+	}
+	if p.Theme != nil {
+		v.validateTheme("theme", p.Theme)
+	}
+	v.validateCommands(p.Commands)
+
+	names := make([]string, 0, len(p.LanguageAssociations))
+	for name := range p.LanguageAssociations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v.validateLanguage(name, p.LanguageAssociations[name])
+	}
+	p.syntaxIndex = nil
+
+	return v.errs
+}
+
+func (v *validator) validateEditor(editor *EditorConfig) {
+	defaults := v.defaults.Editor
+
+	if editor.TabSize <= 0 {
+		v.add("editor.tab_size", "%d must be more than 0", editor.TabSize)
+		editor.TabSize = defaults.TabSize
+	}
+	if editor.FontSize <= 0 {
+		v.add("editor.font_size", "%d must be more than 0", editor.FontSize)
+		editor.FontSize = defaults.FontSize
+	}
+
+	switch editor.TrimTrailingWhitespace {
+	case TrimNone, TrimAll, TrimModified:
+	default:
+		v.add("editor.trim_trailing_whitespace", "'%s' should be %s, %s or %s",
+			editor.TrimTrailingWhitespace, TrimNone, TrimAll, TrimModified)
+		editor.TrimTrailingWhitespace = defaults.TrimTrailingWhitespace
+	}
+
+	// there's nothing to fall back to if the default font is
+	// missing, the editor reports it when it loads the font.
+	if editor.FontPath == defaults.FontPath && editor.FontFace == defaults.FontFace {
+		return
+	}
+	fontPath := filepath.Join(editor.FontPath, editor.FontFace+".ttf")
+	if _, err := os.Stat(fontPath); err != nil {
+		v.add("editor.font_face", "can't find the font %s", fontPath)
+		editor.FontPath, editor.FontFace = defaults.FontPath, defaults.FontFace
+	}
+}
+
+func (v *validator) validateCursor(cursor *CursorConfig) {
+	switch cursor.BlockWidth {
+	case "", "block":
+	default:
+		if width, err := strconv.Atoi(cursor.BlockWidth); err != nil || width < 0 {
+			v.add("cursor.block_width", "'%s' should be block or a width", cursor.BlockWidth)
+			cursor.BlockWidth = v.defaults.Cursor.BlockWidth
+		}
+	}
+
+	if cursor.FlashRate <= 0 {
+		v.add("cursor.flash_rate", "%d must be more than 0", cursor.FlashRate)
+		cursor.FlashRate = v.defaults.Cursor.FlashRate
+	}
+}
+
+// validateColours checks all of the colours in the struct,
+// the colours are the uint32 fields.
+func (v *validator) validateColours(prefix string, val, defaults reflect.Value) {
+	typ := val.Type()
+	for idx := 0; idx < typ.NumField(); idx++ {
+		field := val.Field(idx)
+		key := prefix + "." + tomlName(typ.Field(idx))
+
+		switch field.Kind() {
+		case reflect.Uint32:
+			if field.Uint() > maxColour {
+				v.add(key, "%#x is not a colour", field.Uint())
+				field.SetUint(defaults.Field(idx).Uint())
+			}
+		case reflect.Struct:
+			v.validateColours(key, field, defaults.Field(idx))
+		}
+	}
+}
+
+func (v *validator) validateTheme(prefix string, theme *ThemeConfig) {
+	v.validateColours(prefix, reflect.ValueOf(theme).Elem(), reflect.ValueOf(v.defaults.Theme).Elem())
+
+	names := make([]string, 0, len(theme.Syntax))
+	for name := range theme.Syntax {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// without these the languages own colours are used
+	for _, name := range names {
+		key := prefix + ".syntax." + name
+		colour := theme.Syntax[name]
+		if colour.Foreground > maxColour {
+			v.add(key+".foreground", "%#x is not a colour", colour.Foreground)
+			delete(theme.Syntax, name)
+		} else if colour.Background > maxColour {
+			v.add(key+".background", "%#x is not a colour", colour.Background)
+			delete(theme.Syntax, name)
+		}
+	}
+}
+
+func tomlName(field reflect.StructField) string {
+	if tag := field.Tag.Get("toml"); tag != "" {
+		return strings.Split(tag, ",")[0]
+	}
+	return strings.ToLower(field.Name)
+}
+
+func (v *validator) validateCommands(commands map[string]Command) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := "commands." + name
+
+		if len(knownCommands) > 0 && !knownCommands[name] {
+			v.add(key, "there is no command called %s", name)
+			delete(commands, name)
+			continue
+		}
+
+		if len(knownKeys) == 0 {
+			continue
+		}
+		for _, part := range strings.Split(commands[name].Shortcut, "+") {
+			if knownKeys[part] {
+				continue
+			}
+
+			v.add(key+".shortcut", "unknown key '%s' in %s", part, commands[name].Shortcut)
+			if def, ok := v.defaults.Commands[name]; ok {
+				commands[name] = def
+			} else {
+				delete(commands, name)
+			}
+			break
+		}
+	}
+}
+
+func (v *validator) validateLanguage(name string, lang *LanguageSyntaxConfig) {
+	prefix := "languages." + name
+	defaults := v.defaults.LanguageAssociations[name]
+
+	shebangs := lang.Shebangs[:0]
+	for _, shebang := range lang.Shebangs {
+		if _, err := compileShebang(shebang); err != nil {
+			v.add(prefix+".shebangs", "%s", err.Error())
+			continue
+		}
+		shebangs = append(shebangs, shebang)
+	}
+	lang.Shebangs = shebangs
+
+	rules := make([]string, 0, len(lang.Syntax))
+	for rule := range lang.Syntax {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	for _, rule := range rules {
+		criteria := lang.Syntax[rule]
+		key := prefix + ".syntax." + rule
+
+		broken := false
+		if criteria.Foreground > maxColour {
+			v.add(key+".foreground", "%#x is not a colour", criteria.Foreground)
+			broken = true
+		}
+		if criteria.Background > maxColour {
+			v.add(key+".background", "%#x is not a colour", criteria.Background)
+			broken = true
+		}
+		if criteria.Pattern != "" {
+			if _, err := regexp.Compile(criteria.Pattern); err != nil {
+				v.add(key+".pattern", "%s", err.Error())
+				broken = true
+			}
+		}
+		if !broken {
+			continue
+		}
+
+		// the built in rule if there is one, it's
+		// copied as the defaults are thrown away.
+		if defaults != nil {
+			if def, ok := defaults.Syntax[rule]; ok {
+				copied := *def
+				lang.Syntax[rule] = &copied
+				continue
+			}
+		}
+		delete(lang.Syntax, rule)
+	}
+}
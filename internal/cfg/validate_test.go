@@ -0,0 +1,82 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func errorKeys(errs ConfigErrors) []string {
+	keys := make([]string, len(errs))
+	for idx, err := range errs {
+		keys[idx] = err.(*ValidationError).Key
+	}
+	return keys
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phi-font")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Mono.ttf"), nil, 0644))
+
+	// the known commands and keys are global, so they're
+	// put back for the other tests once we're done.
+	commands, keys := knownCommands, knownKeys
+	t.Cleanup(func() {
+		knownCommands, knownKeys = commands, keys
+	})
+	knownCommands, knownKeys = map[string]bool{}, map[string]bool{}
+
+	RegisterCommands("save", "delete_line")
+	RegisterShortcutKeys("super", "s", "d")
+
+	conf := NewDefaultConfig()
+	conf.Editor.FontPath, conf.Editor.FontFace = dir, "Mono"
+	conf.Commands = map[string]Command{
+		"save":        {Shortcut: "super+s"},
+		"delete_line": {Shortcut: "super+d"},
+	}
+	assert.Empty(t, conf.Validate())
+
+	conf.Editor.TabSize = 0
+	conf.Cursor.BlockWidth = "wide"
+	conf.Theme.Palette.Outline = 0x1000000
+	conf.Commands["save"] = Command{Shortcut: "super+f13"}
+	conf.Commands["fly"] = Command{Shortcut: "super+s"}
+	conf.LanguageAssociations["go"].Syntax["comment"].Pattern = "("
+	conf.LanguageAssociations["go"].Syntax["bogus"] = &SyntaxCriteria{Pattern: "["}
+
+	errs := conf.Validate()
+	assert.Equal(t, []string{
+		"editor.tab_size",
+		"cursor.block_width",
+		"theme.palette.outline",
+		"commands.fly",
+		"commands.save.shortcut",
+		"languages.go.syntax.bogus.pattern",
+		"languages.go.syntax.comment.pattern",
+	}, errorKeys(errs))
+
+	// the broken entries are back to their defaults
+	defaults := NewDefaultConfig()
+	assert.Equal(t, defaults.Editor.TabSize, conf.Editor.TabSize)
+	assert.Equal(t, defaults.Cursor.BlockWidth, conf.Cursor.BlockWidth)
+	assert.Equal(t, defaults.Theme.Palette.Outline, conf.Theme.Palette.Outline)
+	assert.Equal(t, defaults.Commands["save"], conf.Commands["save"])
+	assert.NotContains(t, conf.Commands, "fly")
+	assert.Equal(t, defaults.LanguageAssociations["go"].Syntax["comment"], conf.LanguageAssociations["go"].Syntax["comment"])
+	assert.NotContains(t, conf.LanguageAssociations["go"].Syntax, "bogus")
+	assert.Empty(t, conf.Validate())
+
+	conf.Editor.FontFace = "Missing"
+	assert.Equal(t, []string{"editor.font_face"}, errorKeys(conf.Validate()))
+	assert.Equal(t, defaults.Editor.FontFace, conf.Editor.FontFace)
+
+	// the default font isn't checked, it won't be
+	// in the same place on every system.
+	assert.Empty(t, conf.Validate())
+}
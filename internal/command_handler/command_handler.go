@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/felixangell/phi/internal/cfg"
 	"github.com/felixangell/strife"
+	"log"
 	"strings"
 )
 
@@ -66,29 +67,40 @@ var shortcutMap = map[string]int{
 	"down":  strife.KEY_DOWN,
 }
 
-func mapShortcutKeyword(keyword string) int {
-	if val, ok := shortcutMap[keyword]; ok {
-		return val
+func init() {
+	// so the config can check the shortcuts in it
+	keys := make([]string, 0, len(shortcutMap))
+	for key := range shortcutMap {
+		keys = append(keys, key)
 	}
-	panic(fmt.Sprintf("unsupported keyword %s", keyword))
+	cfg.RegisterShortcutKeys(keys...)
 }
 
-func parseHashCombo(combo string) intHashSetHashKey {
+func mapShortcutKeyword(keyword string) (int, bool) {
+	val, ok := shortcutMap[keyword]
+	return val, ok
+}
+
+func parseHashCombo(combo string) (intHashSetHashKey, error) {
 	hs := newIntHashSet()
 	parts := strings.Split(combo, "+")
 	for _, part := range parts {
-		hs.Store(mapShortcutKeyword(part))
+		key, ok := mapShortcutKeyword(part)
+		if !ok {
+			return "", fmt.Errorf("unsupported keyword %s", part)
+		}
+		hs.Store(key)
 	}
-	return hs.Hash()
+	return hs.Hash(), nil
 }
 
 func (c *CommandHandler) DeduceCommandName(superDown bool, controlDown bool, keys ...int) (string, bool) {
 	hs := newIntHashSet(keys...)
 	if superDown {
-		hs.Store(mapShortcutKeyword("super"))
+		hs.Store(shortcutMap["super"])
 	}
 	if controlDown {
-		hs.Store(mapShortcutKeyword("ctrl"))
+		hs.Store(shortcutMap["ctrl"])
 	}
 
 	cmdName, ok := c.triggers[hs.Hash()]
@@ -97,7 +109,12 @@ func (c *CommandHandler) DeduceCommandName(superDown bool, controlDown bool, key
 
 func populateTriggers(config *cfg.PhiEditorConfig, handler *CommandHandler) {
 	for cmdName, cmd := range config.Commands {
-		hash := parseHashCombo(cmd.Shortcut)
+		// Validate reports these so they're just skipped
+		hash, err := parseHashCombo(cmd.Shortcut)
+		if err != nil {
+			log.Println("Skipping shortcut for", cmdName, err.Error())
+			continue
+		}
 		handler.triggers[hash] = cmdName
 	}
 }
@@ -15,10 +15,11 @@ var dummyConfig = &cfg.PhiEditorConfig{
 }
 
 func TestBuildsHashComboCorrectly(t *testing.T) {
-	hash := parseHashCombo("super+d")
+	hash, err := parseHashCombo("super+d")
+	assert.NoError(t, err)
 
 	hs := newIntHashSet(
-		mapShortcutKeyword("super"), strife.KEY_D)
+		strife.KEY_LGUI, strife.KEY_D)
 	expected := hs.Hash()
 
 	assert.Equal(t, expected, hash)
@@ -30,3 +31,16 @@ func TestSimpleShortcutMapsCorrectly(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "delete_line", victim)
 }
+
+func TestBadShortcutIsSkipped(t *testing.T) {
+	_, err := parseHashCombo("super+f13")
+	assert.Error(t, err)
+
+	ch := newCommandHandler(&cfg.PhiEditorConfig{
+		Commands: map[string]cfg.Command{
+			"save":        {Shortcut: "super+f13"},
+			"delete_line": {Shortcut: "super+d"},
+		},
+	})
+	assert.Len(t, ch.triggers, 1)
+}
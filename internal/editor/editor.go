@@ -27,7 +27,11 @@ func (n *PhiEditor) Resize(w, h int) {
 func (n *PhiEditor) HandleEvent(_ strife.StrifeEvent) {}
 
 func (n *PhiEditor) ApplyConfig(conf *cfg.PhiEditorConfig) {
-	gui.LoadDefaultFont(conf)
+	// Validate has already fallen back to the default
+	// font, without one there's nothing we can draw.
+	if err := gui.LoadDefaultFont(conf); err != nil {
+		log.Fatalln("Failed to load the font, check editor.font_path:", err.Error())
+	}
 	command_handler.SetupCommandHandler(conf)
 
 	mainView := buff.NewView(int(1280.0*cfg.ScaleFactor), int(720.0*cfg.ScaleFactor), conf)
@@ -51,6 +55,9 @@ func (n *PhiEditor) ApplyConfig(conf *cfg.PhiEditorConfig) {
 	}
 
 	n.mainView = mainView
+
+	// anything left over from a crash
+	mainView.OfferRecovery()
 }
 
 func (n *PhiEditor) Update() bool {
@@ -8,13 +8,16 @@ import (
 
 var loadedFont *strife.Font
 
-func LoadDefaultFont(config *cfg.PhiEditorConfig) {
+// LoadDefaultFont loads the font in the config, the
+// current font is kept if it can't be loaded.
+func LoadDefaultFont(config *cfg.PhiEditorConfig) error {
 	fontPath := filepath.Join(config.Editor.FontPath, config.Editor.FontFace+".ttf")
-	var err error
-	loadedFont, err = strife.LoadFont(fontPath, int(14.0*cfg.ScaleFactor))
+	font, err := strife.LoadFont(fontPath, int(14.0*cfg.ScaleFactor))
 	if err != nil {
-		panic(err)
+		return err
 	}
+	loadedFont = font
+	return nil
 }
 
 func GetDefaultFont() *strife.Font {
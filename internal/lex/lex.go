@@ -85,23 +85,18 @@ func (l *Lexer) recognizeSymbol() *Token {
 }
 
 func (l *Lexer) recognizeWord() *Token {
-	for l.hasNext() && (unicode.IsLetter(l.peek()) || unicode.IsDigit(l.peek())) {
+	// words can be joined up with any number of
+	// underscores or dashes, e.g. one-dark-pro
+	for l.hasNext() && isWordRune(l.peek()) {
 		l.consume()
 	}
-
-	if l.hasNext() {
-		curr := l.peek()
-		if curr == '_' || curr == '-' {
-			l.consume()
-			for l.hasNext() && (unicode.IsLetter(l.peek()) || unicode.IsDigit(l.peek())) {
-				l.consume()
-			}
-		}
-	}
-
 	return NewToken(l.captureLexeme(), Word, l.startingPos)
 }
 
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
 func (l *Lexer) captureLexeme() string {
 	return string(l.input[l.startingPos:l.pos])
 }
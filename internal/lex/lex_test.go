@@ -0,0 +1,38 @@
+package lex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lexemes(tokens []*Token) []string {
+	var result []string
+	for _, tok := range tokens {
+		result = append(result, tok.Lexeme)
+	}
+	return result
+}
+
+func TestWordSeparators(t *testing.T) {
+	tokens := New("theme one-dark-pro").Tokenize()
+	assert.Equal(t, []string{"theme", "one-dark-pro"}, lexemes(tokens))
+	assert.True(t, tokens[1].IsType(Word))
+
+	tokens = New("encoding reopen x-mac-roman").Tokenize()
+	assert.Equal(t, []string{"encoding", "reopen", "x-mac-roman"}, lexemes(tokens))
+
+	tokens = New("snake_case_name mixed-up_word2").Tokenize()
+	assert.Equal(t, []string{"snake_case_name", "mixed-up_word2"}, lexemes(tokens))
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := New(`! save "my file" 'c' 12.5`).Tokenize()
+	assert.Equal(t, []string{"!", "save", `"my file"`, "'c'", "12.5"}, lexemes(tokens))
+
+	types := []TokenType{Symbol, Word, String, Character, Number}
+	for idx, tok := range tokens {
+		assert.True(t, tok.IsType(types[idx]), tok.String())
+	}
+	assert.Equal(t, 7, tokens[2].Start)
+}
@@ -0,0 +1,234 @@
+// Package diff finds the differences between
+// two versions of a document, line by line.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op uint8
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is a run of lines that are either the same in
+// both a and b, only in b (Insert) or only in a (Delete).
+// it covers the lines a[AStart:AEnd] and b[BStart:BEnd].
+type Edit struct {
+	Op           Op
+	AStart, AEnd int
+	BStart, BEnd int
+}
+
+// Lines returns the edits that turn a into b, this is
+// the shortest edit script found with Myers' algorithm.
+func Lines(a, b []string) []Edit {
+	// most changes are small, so we only
+	// diff what is between the common ends.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	add := func(op Op, x, y int) {
+		if n := len(edits); n > 0 && edits[n-1].Op == op {
+			last := &edits[n-1]
+			last.AEnd, last.BEnd = x, y
+			return
+		}
+
+		start := Edit{Op: op}
+		if n := len(edits); n > 0 {
+			start.AStart, start.BStart = edits[n-1].AEnd, edits[n-1].BEnd
+		}
+		start.AEnd, start.BEnd = x, y
+		edits = append(edits, start)
+	}
+
+	if prefix > 0 {
+		add(Equal, prefix, prefix)
+	}
+	for _, step := range shortestEdit(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		add(step.op, prefix+step.x, prefix+step.y)
+	}
+	if suffix > 0 {
+		add(Equal, len(a), len(b))
+	}
+	return edits
+}
+
+// a single step through the edit graph, x and
+// y are where we are after the step is taken.
+type step struct {
+	op   Op
+	x, y int
+}
+
+func shortestEdit(a, b []string) []step {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v holds the furthest x reached on each diagonal k,
+	// offset so that k = -max is at index 0. we keep the
+	// part of v each round could see so we can go back
+	// through it to find the path.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[max-d:max+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, x, y int) []step {
+	var steps []step
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] starts at diagonal -d
+		v := func(k int) int {
+			return trace[d][k+d]
+		}
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			steps = append(steps, step{Equal, x, y})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				steps = append(steps, step{Insert, x, y})
+			} else {
+				steps = append(steps, step{Delete, x, y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	// we went backwards from the end
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
+
+// Unified returns the differences between a and b in the
+// unified diff format, with the given lines of context around
+// each change.
+func Unified(aName, bName string, a, b []string, context int) string {
+	edits := Lines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+
+	for start := 0; start < len(edits); {
+		if edits[start].Op == Equal {
+			start++
+			continue
+		}
+
+		// a hunk carries on through any equal runs that
+		// are small enough for the context to overlap.
+		end := start + 1
+		for end < len(edits) {
+			e := edits[end]
+			if e.Op == Equal && (e.AEnd-e.AStart > 2*context || end == len(edits)-1) {
+				break
+			}
+			end++
+		}
+
+		first, last := edits[start], edits[end-1]
+		aStart := maxInt(first.AStart-context, 0)
+		bStart := maxInt(first.BStart-context, 0)
+		aEnd := minInt(last.AEnd+context, len(a))
+		bEnd := minInt(last.BEnd+context, len(b))
+
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aStart+1, aEnd-aStart, bStart+1, bEnd-bStart)
+		for _, line := range a[aStart:first.AStart] {
+			sb.WriteString(" " + line + "\n")
+		}
+		for _, e := range edits[start:end] {
+			switch e.Op {
+			case Equal:
+				for _, line := range a[e.AStart:e.AEnd] {
+					sb.WriteString(" " + line + "\n")
+				}
+			case Delete:
+				for _, line := range a[e.AStart:e.AEnd] {
+					sb.WriteString("-" + line + "\n")
+				}
+			case Insert:
+				for _, line := range b[e.BStart:e.BEnd] {
+					sb.WriteString("+" + line + "\n")
+				}
+			}
+		}
+		for _, line := range a[last.AEnd:aEnd] {
+			sb.WriteString(" " + line + "\n")
+		}
+
+		start = end
+	}
+
+	return sb.String()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
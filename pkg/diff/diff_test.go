@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// apply rebuilds b from a and the edits.
+func apply(a, b []string, edits []Edit) []string {
+	var result []string
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			result = append(result, a[e.AStart:e.AEnd]...)
+		case Insert:
+			result = append(result, b[e.BStart:e.BEnd]...)
+		}
+	}
+	return result
+}
+
+func TestLines(t *testing.T) {
+	cases := [][2]string{
+		{"a b c a b b a", "c b a b a c"},
+		{"", "a b c"},
+		{"a b c", ""},
+		{"a b c", "a b c"},
+		{"a b c d e", "a x c d y"},
+		{"x", "y"},
+	}
+
+	for _, c := range cases {
+		a, b := strings.Fields(c[0]), strings.Fields(c[1])
+		edits := Lines(a, b)
+		assert.Equal(t, strings.Join(b, " "), strings.Join(apply(a, b, edits), " "), c)
+	}
+
+	// the shortest edit here is 5 steps
+	edits := Lines(strings.Fields("a b c a b b a"), strings.Fields("c b a b a c"))
+	changed := 0
+	for _, e := range edits {
+		if e.Op != Equal {
+			changed += (e.AEnd - e.AStart) + (e.BEnd - e.BStart)
+		}
+	}
+	assert.Equal(t, 5, changed)
+}
+
+func TestUnified(t *testing.T) {
+	a := strings.Split("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine", "\n")
+	b := strings.Split("one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten", "\n")
+
+	assert.Equal(t, `--- a
++++ b
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+ three
+@@ -9,1 +9,2 @@
+ nine
++ten
+`, Unified("a", "b", a, b, 1))
+}
+
+func TestMerge3(t *testing.T) {
+	base := strings.Fields("a b c d e f g")
+
+	// changes to different lines merge cleanly
+	merged, conflicts := Merge3(base,
+		strings.Fields("a B c d e f g"),
+		strings.Fields("a b c d e F g h"),
+		"buffer", "disk")
+	assert.Equal(t, 0, conflicts)
+	assert.Equal(t, "a B c d e F g h", strings.Join(merged, " "))
+
+	// the same change on both sides isn't a conflict
+	merged, conflicts = Merge3(base,
+		strings.Fields("a b x d e f g"),
+		strings.Fields("a b x d e f"),
+		"buffer", "disk")
+	assert.Equal(t, 0, conflicts)
+	assert.Equal(t, "a b x d e f", strings.Join(merged, " "))
+
+	merged, conflicts = Merge3(base,
+		strings.Fields("a x y d e f g"),
+		strings.Fields("a b z d e f g"),
+		"buffer", "disk")
+	assert.Equal(t, 1, conflicts)
+	assert.Equal(t, []string{
+		"a",
+		"<<<<<<< buffer", "x", "y",
+		"=======", "b", "z",
+		">>>>>>> disk",
+		"d", "e", "f", "g",
+	}, merged)
+
+	// both inserting at the same place
+	merged, conflicts = Merge3(base,
+		strings.Fields("a b c d e f g x"),
+		strings.Fields("a b c d e f g y"),
+		"buffer", "disk")
+	assert.Equal(t, 1, conflicts)
+	assert.Equal(t, "a b c d e f g <<<<<<< buffer x ======= y >>>>>>> disk", strings.Join(merged, " "))
+}
@@ -0,0 +1,139 @@
+package diff
+
+// Hunk is a change that replaces the lines a[AStart:AEnd]
+// with the lines b[BStart:BEnd].
+type Hunk struct {
+	AStart, AEnd int
+	BStart, BEnd int
+}
+
+// Hunks groups the edits into the changes made, a deletion
+// followed by an insertion is a single change.
+func Hunks(edits []Edit) []Hunk {
+	var hunks []Hunk
+	for idx, e := range edits {
+		if e.Op == Equal {
+			continue
+		}
+		if idx > 0 && edits[idx-1].Op != Equal {
+			last := &hunks[len(hunks)-1]
+			last.AEnd, last.BEnd = e.AEnd, e.BEnd
+			continue
+		}
+		hunks = append(hunks, Hunk{e.AStart, e.AEnd, e.BStart, e.BEnd})
+	}
+	return hunks
+}
+
+// the changes both sides made to the same part of the base.
+type mergeGroup struct {
+	start, end   int
+	ours, theirs []Hunk
+}
+
+// overlaps returns if the hunk touches any of the lines in the
+// group, or if they are both changing the same spot.
+func (g *mergeGroup) overlaps(h Hunk) bool {
+	return (h.AStart < g.end && g.start < h.AEnd) || h.AStart == g.start
+}
+
+func (g *mergeGroup) add(h Hunk, ours bool) {
+	if ours {
+		g.ours = append(g.ours, h)
+	} else {
+		g.theirs = append(g.theirs, h)
+	}
+	if h.AEnd > g.end {
+		g.end = h.AEnd
+	}
+}
+
+// apply returns the lines of the group
+// with the given sides changes made.
+func (g *mergeGroup) apply(base, side []string, hunks []Hunk) []string {
+	var result []string
+	pos := g.start
+	for _, h := range hunks {
+		result = append(result, base[pos:h.AStart]...)
+		result = append(result, side[h.BStart:h.BEnd]...)
+		pos = h.AEnd
+	}
+	return append(result, base[pos:g.end]...)
+}
+
+// Merge3 merges the changes made to base in ours and theirs.
+// changes that don't overlap are applied as they are, if both
+// sides change the same lines differently then both versions
+// are put in the result between conflict markers labelled with
+// the given names. the number of conflicts is returned.
+func Merge3(base, ours, theirs []string, oursName, theirsName string) ([]string, int) {
+	ourHunks := Hunks(Lines(base, ours))
+	theirHunks := Hunks(Lines(base, theirs))
+
+	var result []string
+	conflicts := 0
+
+	pos := 0
+	i, j := 0, 0
+	for i < len(ourHunks) || j < len(theirHunks) {
+		var g mergeGroup
+		if j >= len(theirHunks) || (i < len(ourHunks) && ourHunks[i].AStart <= theirHunks[j].AStart) {
+			g = mergeGroup{start: ourHunks[i].AStart, end: ourHunks[i].AEnd}
+			g.ours = append(g.ours, ourHunks[i])
+			i++
+		} else {
+			g = mergeGroup{start: theirHunks[j].AStart, end: theirHunks[j].AEnd}
+			g.theirs = append(g.theirs, theirHunks[j])
+			j++
+		}
+
+		// keep pulling in hunks until nothing else overlaps
+		for {
+			if i < len(ourHunks) && g.overlaps(ourHunks[i]) {
+				g.add(ourHunks[i], true)
+				i++
+				continue
+			}
+			if j < len(theirHunks) && g.overlaps(theirHunks[j]) {
+				g.add(theirHunks[j], false)
+				j++
+				continue
+			}
+			break
+		}
+
+		result = append(result, base[pos:g.start]...)
+		pos = g.end
+
+		ourLines := g.apply(base, ours, g.ours)
+		theirLines := g.apply(base, theirs, g.theirs)
+
+		switch {
+		case len(g.theirs) == 0:
+			result = append(result, ourLines...)
+		case len(g.ours) == 0 || equalLines(ourLines, theirLines):
+			result = append(result, theirLines...)
+		default:
+			conflicts++
+			result = append(result, "<<<<<<< "+oursName)
+			result = append(result, ourLines...)
+			result = append(result, "=======")
+			result = append(result, theirLines...)
+			result = append(result, ">>>>>>> "+theirsName)
+		}
+	}
+
+	return append(result, base[pos:]...), conflicts
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer coalesces bursts of triggers for the same key, the
+// function is only called once a key has gone quiet for the delay.
+// saving a file will often cause a write, chmod and rename at once
+// and we only want to deal with it the once.
+type Debouncer struct {
+	delay time.Duration
+	fire  func(key string, flags uint32)
+
+	mu      sync.Mutex
+	pending map[string]*pendingKey
+	stopped bool
+}
+
+type pendingKey struct {
+	timer *time.Timer
+	flags uint32
+}
+
+// NewDebouncer creates a debouncer that calls fire with the key,
+// from another goroutine, once it hasn't been triggered for delay.
+func NewDebouncer(delay time.Duration, fire func(key string, flags uint32)) *Debouncer {
+	return &Debouncer{
+		delay:   delay,
+		fire:    fire,
+		pending: map[string]*pendingKey{},
+	}
+}
+
+// Trigger starts the wait for the key again. the flags of
+// every trigger in a burst are or'd together for fire.
+func (d *Debouncer) Trigger(key string, flags uint32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	p, ok := d.pending[key]
+	if ok {
+		p.timer.Stop()
+	} else {
+		p = &pendingKey{}
+		d.pending[key] = p
+	}
+	p.flags |= flags
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		// it may have been triggered again as this was
+		// firing, in which case the newer timer wins.
+		if d.pending[key] != p || p.timer != timer {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.pending, key)
+		d.mu.Unlock()
+
+		d.fire(key, p.flags)
+	})
+	p.timer = timer
+}
+
+// Stop cancels anything that is waiting to fire,
+// later triggers are ignored.
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+	for key, p := range d.pending {
+		p.timer.Stop()
+		delete(d.pending, key)
+	}
+}
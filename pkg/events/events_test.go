@@ -0,0 +1,93 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	var q Queue
+
+	const writers, count = 8, 1000
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for idx := 0; idx < count; idx++ {
+				q.Push(fmt.Sprintf("%d:%d", w, idx))
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// drain as the writers are going, like the main loop would.
+	var drained []interface{}
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		drained = append(drained, q.Drain()...)
+	}
+
+	assert.Len(t, drained, writers*count)
+	assert.Equal(t, 0, q.Len())
+
+	// each writers events stay in order
+	next := make([]int, writers)
+	for _, e := range drained {
+		var w, idx int
+		fmt.Sscanf(e.(string), "%d:%d", &w, &idx)
+		assert.Equal(t, next[w], idx)
+		next[w]++
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	var q Queue
+	d := NewDebouncer(20*time.Millisecond, func(key string, flags uint32) {
+		q.Push(fmt.Sprintf("%s %d", key, flags))
+	})
+
+	// a burst from a few goroutines, like the
+	// write, chmod and rename of a save.
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := 0; idx < 10; idx++ {
+				d.Trigger("a.txt", 1)
+				d.Trigger("a.txt", 4)
+				d.Trigger("b.txt", 2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Eventually(t, func() bool {
+		return q.Len() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// make sure nothing else turns up late
+	time.Sleep(50 * time.Millisecond)
+	assert.ElementsMatch(t, []interface{}{"a.txt 5", "b.txt 2"}, q.Drain())
+
+	d.Trigger("c.txt", 1)
+	d.Stop()
+	d.Trigger("d.txt", 1)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, q.Len())
+}
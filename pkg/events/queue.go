@@ -0,0 +1,36 @@
+// Package events is for passing events from other goroutines,
+// like the file watcher, over to the main thread to be handled.
+package events
+
+import "sync"
+
+// Queue is a list of events that can be pushed to from
+// any goroutine, and drained by whoever handles them.
+type Queue struct {
+	mu     sync.Mutex
+	events []interface{}
+}
+
+// Push adds the event to the end of the queue.
+func (q *Queue) Push(event interface{}) {
+	q.mu.Lock()
+	q.events = append(q.events, event)
+	q.mu.Unlock()
+}
+
+// Drain empties the queue, returning all of the
+// events in the order they were pushed.
+func (q *Queue) Drain() []interface{} {
+	q.mu.Lock()
+	events := q.events
+	q.events = nil
+	q.mu.Unlock()
+	return events
+}
+
+// Len returns how many events are waiting.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.events)
+}
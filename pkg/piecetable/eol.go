@@ -0,0 +1,107 @@
+package piecetable
+
+import "bytes"
+
+// LineEnding is the style of line separator
+// that is used when the document is written out.
+type LineEnding uint8
+
+const (
+	LF LineEnding = iota
+	CRLF
+	CR
+)
+
+var lineEndings = map[LineEnding]string{
+	LF:   "\n",
+	CRLF: "\r\n",
+	CR:   "\r",
+}
+
+func (e LineEnding) String() string {
+	switch e {
+	case CRLF:
+		return "CRLF"
+	case CR:
+		return "CR"
+	}
+	return "LF"
+}
+
+// Separator returns the bytes that go between each line.
+func (e LineEnding) Separator() string {
+	return lineEndings[e]
+}
+
+// detectLineEnding guesses the line ending from the
+// first line break, files without any are LF.
+func detectLineEnding(data []byte) LineEnding {
+	idx := bytes.IndexAny(data, "\r\n")
+	switch {
+	case idx == -1 || data[idx] == '\n':
+		return LF
+	case idx+1 < len(data) && data[idx+1] == '\n':
+		return CRLF
+	}
+	return CR
+}
+
+// lineEndingBit is the bit for the ending in
+// the set of endings that indexLines has seen.
+func lineEndingBit(data []byte, idx, sepLen int) uint8 {
+	switch {
+	case sepLen == 2:
+		return 1 << CRLF
+	case data[idx] == '\r':
+		return 1 << CR
+	}
+	return 1 << LF
+}
+
+// mixedEndings returns if more than one
+// ending is in the set of endings seen.
+func mixedEndings(seen uint8) bool {
+	return seen&(seen-1) != 0
+}
+
+// nextLineBreak finds the next line break from the given
+// offset and the length of it. any of the line endings are
+// treated as a break, so lines never hold a separator even if
+// the file has a mix of them.
+func nextLineBreak(data []byte, from int) (int, int) {
+	idx := bytes.IndexAny(data[from:], "\r\n")
+	if idx == -1 {
+		return len(data), 0
+	}
+
+	idx += from
+	if data[idx] == '\r' && idx+1 < len(data) && data[idx+1] == '\n' {
+		return idx, 2
+	}
+	return idx, 1
+}
+
+// LineEnding returns the line ending that is used
+// when the document is written out.
+func (p *PieceTable) LineEnding() LineEnding {
+	return p.ending
+}
+
+// MixedLineEndings returns if the document had more than one
+// kind of line ending when it was loaded. they are all written
+// out as LineEnding, so the file won't be the same once saved.
+func (p *PieceTable) MixedLineEndings() bool {
+	return p.mixed
+}
+
+// SetLineEnding changes the line ending the document is
+// written out with. this is what every line uses from now
+// on so the document no longer has mixed line endings.
+func (p *PieceTable) SetLineEnding(ending LineEnding) {
+	p.mixed = false
+	if ending == p.ending {
+		return
+	}
+	p.ending = ending
+	p.version++
+}
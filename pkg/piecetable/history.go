@@ -0,0 +1,232 @@
+package piecetable
+
+import "time"
+
+// undoState is a version of the document in the undo tree.
+// making a change after undoing creates a new branch rather
+// than throwing away the changes that were undone.
+type undoState struct {
+	seq    int
+	time   time.Time
+	depth  int
+	parent *undoState
+
+	// the branches made from this state, and the
+	// branch that we will follow when we redo.
+	children []*undoState
+	redo     *undoState
+
+	// the group of changes that take the
+	// parent state to this state.
+	changes []*PieceNode
+	group   uint64
+}
+
+// UndoState describes a version of the document
+// in the undo tree of the piece table.
+type UndoState struct {
+	Seq     int
+	Parent  int
+	Time    time.Time
+	Changes int
+}
+
+func (s *undoState) describe() UndoState {
+	parent := -1
+	if s.parent != nil {
+		parent = s.parent.seq
+	}
+	return UndoState{s.seq, parent, s.time, len(s.changes)}
+}
+
+func (p *PieceTable) resetHistory() {
+	root := &undoState{time: time.Now()}
+	p.states = []*undoState{root}
+	p.current = root
+}
+
+// push adds the change to the undo tree, it becomes a new
+// state unless it is in the same group as the current state.
+func (p *PieceTable) push(node *PieceNode) {
+	curr := p.current
+	if curr.parent != nil && curr.group == node.group && len(curr.children) == 0 {
+		curr.changes = append(curr.changes, node)
+		curr.time = time.Now()
+		return
+	}
+
+	state := &undoState{
+		seq:     len(p.states),
+		time:    time.Now(),
+		depth:   curr.depth + 1,
+		parent:  curr,
+		changes: []*PieceNode{node},
+		group:   node.group,
+	}
+
+	curr.children = append(curr.children, state)
+	curr.redo = state
+
+	p.states = append(p.states, state)
+	p.current = state
+}
+
+func (p *PieceTable) apply(node *PieceNode) {
+	// a change read back from disk has no spans
+	// yet, so we make them from its text.
+	if node.inserted == nil {
+		node.inserted = p.addText(node.Data)
+		node.removed = p.replace(node.Pos(), endOfText(node.Pos(), node.Removed), node.inserted)
+		return
+	}
+	p.replace(node.Pos(), endOf(node.Pos(), node.removed), node.inserted)
+}
+
+func (p *PieceTable) revert(node *PieceNode) {
+	if node.removed == nil {
+		node.removed = p.addText(node.Removed)
+		node.inserted = p.replace(node.Pos(), endOfText(node.Pos(), node.Data), node.removed)
+		return
+	}
+	p.replace(node.Pos(), endOf(node.Pos(), node.inserted), node.removed)
+}
+
+// leave reverts the current state, moving to its parent.
+func (p *PieceTable) leave() *PieceNode {
+	state := p.current
+	for idx := len(state.changes) - 1; idx >= 0; idx-- {
+		p.revert(state.changes[idx])
+	}
+
+	state.parent.redo = state
+	p.current = state.parent
+	return state.changes[0]
+}
+
+// enter applies the given child of the current state.
+func (p *PieceTable) enter(state *undoState) *PieceNode {
+	for _, change := range state.changes {
+		p.apply(change)
+	}
+
+	state.parent.redo = state
+	p.current = state
+	return state.changes[0]
+}
+
+// BeginGroup starts an undo group, every change made until
+// the matching EndGroup is undone and redone as a single step.
+// groups can be nested, only the outermost group counts.
+func (p *PieceTable) BeginGroup() {
+	if p.groupDepth == 0 {
+		p.group++
+	}
+	p.groupDepth++
+}
+
+// EndGroup ends the undo group started by BeginGroup.
+func (p *PieceTable) EndGroup() {
+	if p.groupDepth > 0 {
+		p.groupDepth--
+	}
+}
+
+// Redo re-applies the most recently undone group of changes,
+// following the branch that was last visited. the first change
+// in the group is returned.
+func (p *PieceTable) Redo() *PieceNode {
+	p.groupDepth = 0
+
+	if p.current.redo == nil {
+		return nil
+	}
+	return p.enter(p.current.redo)
+}
+
+// Undo reverts the most recent group of changes, the
+// first change in the group is returned.
+func (p *PieceTable) Undo() *PieceNode {
+	// we can't undo half of a group.
+	p.groupDepth = 0
+
+	if p.current.parent == nil {
+		return nil
+	}
+	return p.leave()
+}
+
+// GotoState moves the document to the state with the given
+// sequence number, which can be on another branch of the undo
+// tree. the last change that was applied or reverted is
+// returned, or nil if nothing changed.
+func (p *PieceTable) GotoState(seq int) *PieceNode {
+	p.groupDepth = 0
+
+	if seq < 0 || seq >= len(p.states) {
+		return nil
+	}
+
+	// walk up from both states until we find
+	// the state they branched from
+	from, to := p.current, p.states[seq]
+
+	var path []*undoState
+	for from != to {
+		if from.depth >= to.depth {
+			from = from.parent
+		}
+		if to.depth > from.depth {
+			path = append(path, to)
+			to = to.parent
+		}
+	}
+
+	var last *PieceNode
+	for p.current != from {
+		last = p.leave()
+	}
+	for idx := len(path) - 1; idx >= 0; idx-- {
+		last = p.enter(path[idx])
+	}
+	return last
+}
+
+// GotoTime moves the document to the most recent
+// state that existed at the given time.
+func (p *PieceTable) GotoTime(t time.Time) *PieceNode {
+	seq := 0
+	for idx, state := range p.states {
+		if state.time.After(t) {
+			break
+		}
+		seq = idx
+	}
+	return p.GotoState(seq)
+}
+
+// CurrentState returns the sequence number
+// of the current state in the undo tree.
+func (p *PieceTable) CurrentState() int {
+	return p.current.seq
+}
+
+// UndoStates returns every state in the undo tree.
+func (p *PieceTable) UndoStates() []UndoState {
+	result := make([]UndoState, len(p.states))
+	for idx, state := range p.states {
+		result[idx] = state.describe()
+	}
+	return result
+}
+
+// UndoBranches returns the state at the tip of
+// each branch in the undo tree.
+func (p *PieceTable) UndoBranches() []UndoState {
+	var result []UndoState
+	for _, state := range p.states {
+		if len(state.children) == 0 {
+			result = append(result, state.describe())
+		}
+	}
+	return result
+}
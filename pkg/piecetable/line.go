@@ -1,53 +1,183 @@
 package piecetable
 
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type bufferKind uint8
+
+const (
+	originalBuffer bufferKind = iota
+	addBuffer
+)
+
+// span is a slice of either the original
+// or the add buffer of the piece table.
+type span struct {
+	buf    bufferKind
+	start  int
+	length int
+}
+
+// Line is a list of spans that when stitched
+// together make up the contents of the line.
+//
+// a line is never modified once it has been made,
+// edits create a new line with a new span list.
 type Line struct {
-	Buffer string
 	parent *PieceTable
-	mods   map[int]bool
-	keys   []int
+	spans  []span
+	length int
+
+	// the number of characters (runes) in the line
+	runes int
 }
 
-func NewLine(data string, parent *PieceTable) *Line {
-	return &Line{
-		data, parent, map[int]bool{}, []int{},
+func newLineFromSpans(spans []span, parent *PieceTable) *Line {
+	line := &Line{parent: parent, spans: spans}
+	for _, s := range spans {
+		line.length += s.length
+		line.runes += utf8.RuneCount(parent.bytes(s))
 	}
+	return line
 }
 
-func (l *Line) AppendNode(node *PieceNode) {
-	nodeIndex := len(l.parent.nodes)
-	l.mods[nodeIndex] = true
-	l.keys = append(l.keys, nodeIndex)
-	l.parent.nodes = append(l.parent.nodes, node)
+// Buffer returns the contents of the line.
+//
+// Deprecated: this used to be the text the line was made
+// from before any edits, use String instead.
+func (l *Line) Buffer() string {
+	return l.String()
 }
 
+// Len returns the length of the line in bytes.
 func (l *Line) Len() int {
-	return len(l.String())
+	return l.length
+}
+
+// RuneCount returns the number of characters in the line.
+func (l *Line) RuneCount() int {
+	return l.runes
+}
+
+// byteOffset converts a column into a byte offset
+// in the line, columns past the end are clamped.
+func (l *Line) byteOffset(col int) int {
+	if col <= 0 {
+		return 0
+	}
+	if col >= l.runes {
+		return l.length
+	}
+	if l.runes == l.length {
+		return col
+	}
+
+	for offs := range l.String() {
+		if col == 0 {
+			return offs
+		}
+		col--
+	}
+	return l.length
+}
+
+// column converts a byte offset in the line into a column,
+// an offset in the middle of a character is rounded down.
+func (l *Line) column(offs int) int {
+	if offs <= 0 {
+		return 0
+	}
+	if offs >= l.length {
+		return l.runes
+	}
+	if l.runes == l.length {
+		return offs
+	}
+
+	str := l.String()
+	col := 0
+	for idx := 0; idx < offs; col++ {
+		_, size := utf8.DecodeRuneInString(str[idx:])
+		if idx+size > offs {
+			break
+		}
+		idx += size
+	}
+	return col
 }
 
 func (l *Line) String() string {
-	data := l.Buffer
+	return l.parent.spansString(l.spans)
+}
 
-	for _, keyName := range l.keys {
-		thing, ok := l.mods[keyName]
-		// ?
-		if !ok || !thing {
-			continue
+// splitAt returns a copy of the span list with a span
+// boundary at the given offset, along with the index of
+// the span that starts at that offset.
+func (l *Line) splitAt(offs int) ([]span, int) {
+	spans := make([]span, 0, len(l.spans)+1)
+
+	pos := 0
+	for idx, s := range l.spans {
+		if offs == pos {
+			return append(spans, l.spans[idx:]...), idx
 		}
 
-		mod := l.parent.nodes[keyName]
+		if offs < pos+s.length {
+			left := span{s.buf, s.start, offs - pos}
+			right := span{s.buf, s.start + left.length, s.length - left.length}
+
+			spans = append(spans, left, right)
+			return append(spans, l.spans[idx+1:]...), idx + 1
+		}
+
+		spans = append(spans, s)
+		pos += s.length
+	}
+	return spans, len(spans)
+}
 
-		if mod.Length >= 0 {
-			if mod.Start >= len(data) {
-				data += mod.Data
+// slice returns the spans that make up the
+// bytes from..to of this line.
+func (l *Line) slice(from, to int) []span {
+	if to > l.length {
+		to = l.length
+	}
+	if from >= to {
+		return nil
+	}
+
+	split, start := l.splitAt(from)
+	tail := &Line{parent: l.parent, spans: split[start:], length: l.length - from}
+	rest, end := tail.splitAt(to - from)
+	return rest[:end]
+}
+
+// appendSpans appends the spans to dst, merging any
+// spans that follow on from each other in the same buffer.
+// this is quite common, e.g. when typing out a word.
+func appendSpans(dst []span, spans ...span) []span {
+	for _, s := range spans {
+		if s.length == 0 {
+			continue
+		}
+		if n := len(dst); n > 0 {
+			last := &dst[n-1]
+			if last.buf == s.buf && last.start+last.length == s.start {
+				last.length += s.length
 				continue
 			}
-
-			fst, end := data[:mod.Start], data[mod.Start:]
-			data = fst + mod.Data + end
-		} else {
-			data = data[:mod.Start-1] + data[mod.Start:]
 		}
+		dst = append(dst, s)
 	}
+	return dst
+}
 
-	return data
+func (p *PieceTable) spansString(spans []span) string {
+	var sb strings.Builder
+	for _, s := range spans {
+		sb.Write(p.bytes(s))
+	}
+	return sb.String()
 }
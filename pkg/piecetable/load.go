@@ -0,0 +1,189 @@
+package piecetable
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+)
+
+// files bigger than this are read in and indexed
+// in the background rather than all up front.
+var lazyLoadSize int64 = 16 << 20
+
+// how much of a big file is indexed before LoadFile
+// returns, this is more than enough for the first screen.
+var firstChunkSize = 64 << 10
+
+// loader builds the line index of a big file.
+type loader struct {
+	done chan *lineNode
+	size int64
+
+	// bytes indexed so far, this is
+	// written to by the loading goroutine.
+	progress int64
+
+	// the line endings in the whole file and the
+	// bytes that were read, these are set before
+	// the lines are sent on done.
+	seen     uint8
+	original []byte
+}
+
+// LoadFile loads the file at the given path into a new table.
+// big files are read in and indexed in the background, only the
+// first few lines are read and indexed up front. the table is
+// read only until Loading reports that it is done.
+//
+// NOTE: big files used to be mapped into memory, but another
+// program truncating the file would crash us the next time we
+// read the table. the whole file is read in now so the table
+// never changes under us, whatever happens to the file.
+func LoadFile(path string) (*PieceTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if info.Size() < lazyLoadSize {
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return MakePieceTable(string(data)), nil
+	}
+
+	data := make([]byte, info.Size())
+	n, err := readFirstChunk(f, data)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	table := &PieceTable{
+		original: data[:n],
+	}
+	table.resetHistory()
+	table.ending = detectLineEnding(data[:min(n, firstChunkSize)])
+	lines, seen := table.indexLines(data[:n], firstChunkSize, nil)
+	table.root = buildLines(lines)
+	table.mixed = mixedEndings(seen)
+
+	ld := &loader{
+		done: make(chan *lineNode, 1),
+		size: info.Size(),
+	}
+	table.loader = ld
+
+	// only the bytes before n are used until the lines are
+	// swapped in, so the rest can be read in underneath them.
+	go func() {
+		defer f.Close()
+
+		// if the file got shorter since we looked at it,
+		// we make do with what was there.
+		m, _ := io.ReadFull(f, data[n:])
+		data = data[:n+m]
+
+		lines, seen := table.indexLines(data, len(data), &ld.progress)
+		ld.seen = seen
+		ld.original = data
+		ld.done <- buildLines(lines)
+	}()
+
+	return table, nil
+}
+
+// readFirstChunk reads the start of f into data, going
+// on past firstChunkSize until the line that crosses it
+// has ended, so that indexLines never sees half a line.
+func readFirstChunk(f *os.File, data []byte) (int, error) {
+	n := 0
+	for n < len(data) {
+		m, err := io.ReadFull(f, data[n:min(len(data), n+firstChunkSize)])
+		n += m
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		// the byte at firstChunkSize could be the end of a
+		// \r\n that started before it, so we look after that,
+		// and one byte past the break is kept so a \r\n isn't
+		// split down the middle.
+		if n > firstChunkSize+2 && bytes.IndexAny(data[firstChunkSize+1:n-1], "\r\n") != -1 {
+			break
+		}
+	}
+	return n, nil
+}
+
+// indexLines splits data, the start of the original buffer,
+// into lines, stopping after the line that crosses limit. progress is updated with
+// the number of bytes indexed as we go. the set of line endings
+// that were found is returned with the lines.
+func (p *PieceTable) indexLines(data []byte, limit int, progress *int64) ([]*Line, uint8) {
+	var lines []*Line
+	var seen uint8
+
+	start := 0
+	for {
+		end, sepLen := nextLineBreak(data, start)
+
+		var spans []span
+		if end > start {
+			spans = []span{{originalBuffer, start, end - start}}
+		}
+		lines = append(lines, newLineFromSpans(spans, p))
+
+		if end == len(data) || end >= limit {
+			return lines, seen
+		}
+		seen |= lineEndingBit(data, end, sepLen)
+		start = end + sepLen
+
+		if progress != nil && len(lines)%4096 == 0 {
+			atomic.StoreInt64(progress, int64(start))
+		}
+	}
+}
+
+// Loading returns if the file is still being indexed in the
+// background, and how far through it we are from 0 to 1. once
+// the index has been built the whole document is swapped in,
+// so this should be polled by whoever is using the table.
+func (p *PieceTable) Loading() (bool, float64) {
+	if p.loader == nil {
+		return false, 1
+	}
+
+	select {
+	case root := <-p.loader.done:
+		p.root = root
+		p.original = p.loader.original
+		p.mixed = mixedEndings(p.loader.seen)
+		p.loader = nil
+		p.version++
+		return false, 1
+	default:
+	}
+
+	return true, float64(atomic.LoadInt64(&p.loader.progress)) / float64(p.loader.size)
+}
+
+// ReadOnly returns if the table can't be edited, this
+// is the case while a file is still being loaded.
+func (p *PieceTable) ReadOnly() bool {
+	return p.loader != nil
+}
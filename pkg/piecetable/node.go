@@ -1,17 +1,37 @@
 package piecetable
 
+// PieceNode is a single change made to the piece table,
+// the text Removed was cut out from Index:Start (line:col)
+// and Data was inserted in its place. unlike a Pos, Start
+// is a byte offset into the line, see RuneCol.
 type PieceNode struct {
-	Index  int
-	Start  int
-	Length int
-	Data   string
+	Index   int
+	Start   int
+	Length  int
+	Data    string
+	Removed string
+
+	// the spans that were inserted and removed, one
+	// list per line. this is what lets us undo/redo a
+	// change without touching the buffers again.
+	inserted [][]span
+	removed  [][]span
+
+	// changes in the same group are
+	// undone/redone together.
+	group uint64
 }
 
 func NewPiece(data string, line int, start int) *PieceNode {
 	return &PieceNode{
-		line,
-		start,
-		len(data),
-		data,
+		Index:  line,
+		Start:  start,
+		Length: len(data),
+		Data:   data,
 	}
 }
+
+// Pos returns the position the change starts at.
+func (n *PieceNode) Pos() Pos {
+	return Pos{n.Index, n.Start}
+}
@@ -0,0 +1,124 @@
+package piecetable
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// the undo tree as it is written to disk, the changes
+// only keep their text as the spans point into buffers
+// which wont exist when the history is read back.
+type savedHistory struct {
+	Current int          `json:"current"`
+	States  []savedState `json:"states"`
+}
+
+type savedState struct {
+	Parent  int           `json:"parent"`
+	Redo    int           `json:"redo"`
+	Time    time.Time     `json:"time"`
+	Changes []savedChange `json:"changes"`
+}
+
+type savedChange struct {
+	Line     int    `json:"line"`
+	Col      int    `json:"col"`
+	Inserted string `json:"inserted"`
+	Removed  string `json:"removed"`
+}
+
+// endOfText returns the position at the end of
+// the given text if it was inserted at pos.
+func endOfText(pos Pos, text string) Pos {
+	lastLine := strings.LastIndexByte(text, '\n')
+	if lastLine == -1 {
+		return Pos{pos.Line, pos.Col + len(text)}
+	}
+	return Pos{pos.Line + strings.Count(text, "\n"), len(text) - lastLine - 1}
+}
+
+// WriteHistory writes the undo tree to w. state is the state
+// the document will be in when the history is read back, this
+// is usually the state that was last saved to disk.
+func (p *PieceTable) WriteHistory(w io.Writer, state int) error {
+	if state < 0 || state >= len(p.states) {
+		return errors.New("no such undo state")
+	}
+
+	saved := savedHistory{
+		Current: state,
+		States:  make([]savedState, len(p.states)),
+	}
+
+	for idx, s := range p.states {
+		parent, redo := -1, -1
+		if s.parent != nil {
+			parent = s.parent.seq
+		}
+		if s.redo != nil {
+			redo = s.redo.seq
+		}
+
+		changes := make([]savedChange, len(s.changes))
+		for i, change := range s.changes {
+			changes[i] = savedChange{change.Index, change.Start, change.Data, change.Removed}
+		}
+
+		saved.States[idx] = savedState{parent, redo, s.time, changes}
+	}
+
+	return json.NewEncoder(w).Encode(saved)
+}
+
+// ReadHistory replaces the undo tree with one written by
+// WriteHistory. the document must already be in the state
+// the history was written for, or undoing will go wrong.
+func (p *PieceTable) ReadHistory(r io.Reader) error {
+	var saved savedHistory
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return err
+	}
+
+	if len(saved.States) == 0 || saved.Current < 0 || saved.Current >= len(saved.States) {
+		return errors.New("malformed undo history")
+	}
+
+	states := make([]*undoState, len(saved.States))
+	for idx, s := range saved.States {
+		state := &undoState{seq: idx, time: s.Time}
+
+		if idx > 0 {
+			// parents always come before their children
+			if s.Parent < 0 || s.Parent >= idx || len(s.Changes) == 0 {
+				return errors.New("malformed undo history")
+			}
+			state.parent = states[s.Parent]
+			state.depth = state.parent.depth + 1
+			state.parent.children = append(state.parent.children, state)
+		}
+
+		// the spans for these are filled in the
+		// first time they are applied or reverted.
+		for _, change := range s.Changes {
+			node := NewPiece(change.Inserted, change.Line, change.Col)
+			node.Removed = change.Removed
+			state.changes = append(state.changes, node)
+		}
+
+		states[idx] = state
+	}
+
+	for idx, s := range saved.States {
+		if s.Redo > idx && s.Redo < len(states) && states[s.Redo].parent == states[idx] {
+			states[idx].redo = states[s.Redo]
+		}
+	}
+
+	p.states = states
+	p.current = states[saved.Current]
+	p.groupDepth = 0
+	return nil
+}
@@ -1,8 +1,15 @@
 package piecetable
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -147,3 +154,475 @@ stuff`
 
 	assert.Equal(t, text, table.String(), "Un-modified piece table output doesn't match value expected")
 }
+
+func TestUndoInterleavedEdits(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares`
+	output := `this is not my piece table
+document i want to see how it fares`
+
+	table := MakePieceTable(text)
+	table.Insert("not ", 0, 8)
+	table.Insert("piece table ", 0, 15)
+	for i := 0; i < len(" testing"); i++ {
+		table.Delete(0, table.LineLen(0))
+	}
+
+	fmt.Println(table.String())
+	assert.Equal(t, output, table.String())
+
+	for table.Undo() != nil {
+	}
+	assert.Equal(t, text, table.String())
+
+	for table.Redo() != nil {
+	}
+	assert.Equal(t, output, table.String())
+}
+
+func TestLineIndex(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares
+and all of that fun
+stuff`
+
+	table := MakePieceTable(text)
+	assert.Equal(t, 4, table.LineCount())
+	assert.Equal(t, 19, table.LineOffset(1))
+	assert.Equal(t, 75, table.LineOffset(3))
+
+	line, col := table.OffsetLine(23)
+	assert.Equal(t, 1, line)
+	assert.Equal(t, 4, col)
+
+	table.InsertLine(1, "a new line")
+	table.RemoveLine(3)
+	table.SwapLines(0, 1)
+
+	output := `a new line
+this is my testing
+document i want to see how it fares
+stuff`
+	assert.Equal(t, output, table.String())
+
+	for i := 0; i < 1000; i++ {
+		table.InsertLine(table.LineCount(), fmt.Sprintf("line %d", i))
+	}
+	assert.Equal(t, 1004, table.LineCount())
+	assert.Equal(t, "line 500", table.Line(504))
+
+	line, col = table.OffsetLine(table.LineOffset(504) + 2)
+	assert.Equal(t, 504, line)
+	assert.Equal(t, 2, col)
+}
+
+func TestMultiLineInsertAndDelete(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares
+and all of that fun
+stuff`
+	output := `this is my
+multi line
+piece table testing
+document i want to see how it fares
+and all of that fun
+stuff`
+
+	table := MakePieceTable(text)
+	end := table.InsertAt(Pos{0, 10}, "\nmulti line\npiece table")
+	assert.Equal(t, Pos{2, 11}, end)
+	assert.Equal(t, output, table.String())
+
+	removed := table.DeleteRange(Pos{2, 5}, Pos{4, 7})
+	assert.Equal(t, " table testing\ndocument i want to see how it fares\nand all", removed)
+	assert.Equal(t, `this is my
+multi line
+piece of that fun
+stuff`, table.String())
+
+	table.Undo()
+	assert.Equal(t, output, table.String())
+
+	table.Undo()
+	assert.Equal(t, text, table.String())
+
+	table.Redo()
+	table.Redo()
+	assert.Equal(t, `this is my
+multi line
+piece of that fun
+stuff`, table.String())
+}
+
+func TestUndoStructuralChanges(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares
+and all of that fun
+stuff`
+
+	table := MakePieceTable(text)
+	table.SwapLines(0, 1)
+	table.InsertAt(Pos{1, 7}, "\n")
+	table.SetLine(0, "a new document")
+	table.RemoveLine(3)
+	table.RemoveLine(3)
+	table.InsertLine(3, "more stuff")
+	table.DeleteRange(Pos{0, 14}, Pos{1, 0})
+
+	output := `a new documentthis is
+ my testing
+more stuff`
+	assert.Equal(t, output, table.String())
+
+	for table.Undo() != nil {
+	}
+	assert.Equal(t, text, table.String())
+
+	for table.Redo() != nil {
+	}
+	assert.Equal(t, output, table.String())
+}
+
+func TestUndoGroup(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares`
+	output := `this is my (piece table) testing
+document i want to see how it fares`
+
+	table := MakePieceTable(text)
+
+	table.BeginGroup()
+	for i, r := range "(piece table)" {
+		table.Insert(string(r), 0, 11+i)
+	}
+
+	// nested groups are part of the outer group
+	table.BeginGroup()
+	table.Insert("  ", 0, 24)
+	table.EndGroup()
+	table.Delete(0, 26)
+	table.EndGroup()
+
+	table.Insert("!", 0, 7)
+
+	assert.Equal(t, `this is! my (piece table) testing
+document i want to see how it fares`, table.String())
+
+	table.Undo()
+	assert.Equal(t, output, table.String())
+
+	table.Undo()
+	assert.Equal(t, text, table.String())
+
+	table.Redo()
+	assert.Equal(t, output, table.String())
+}
+
+func TestUndoTreeBranches(t *testing.T) {
+	text := `this is my testing`
+
+	table := MakePieceTable(text)
+	table.Insert("first ", 0, 11)
+	table.Insert("branch ", 0, 17)
+	assert.Equal(t, "this is my first branch testing", table.String())
+
+	// making a change after an undo starts a new
+	// branch instead of throwing the old one away.
+	table.Undo()
+	table.Insert("other ", 0, 17)
+	assert.Equal(t, "this is my first other testing", table.String())
+	assert.Nil(t, table.Redo())
+
+	assert.Len(t, table.UndoBranches(), 2)
+	assert.Equal(t, 3, table.CurrentState())
+
+	table.GotoState(2)
+	assert.Equal(t, "this is my first branch testing", table.String())
+
+	table.GotoState(0)
+	assert.Equal(t, text, table.String())
+
+	// redo follows the branch we last visited
+	table.Redo()
+	table.Redo()
+	assert.Equal(t, "this is my first branch testing", table.String())
+
+	table.GotoState(3)
+	assert.Equal(t, "this is my first other testing", table.String())
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares`
+
+	table := MakePieceTable(text)
+	table.InsertAt(Pos{0, 11}, "piece\ntable ")
+	table.Undo()
+	table.DeleteRange(Pos{0, 4}, Pos{1, 8})
+	output := table.String()
+
+	var buf bytes.Buffer
+	assert.NoError(t, table.WriteHistory(&buf, table.CurrentState()))
+
+	restored := MakePieceTable(output)
+	assert.NoError(t, restored.ReadHistory(&buf))
+	before, after := table.UndoStates(), restored.UndoStates()
+	assert.Equal(t, len(before), len(after))
+	for idx := range before {
+		assert.True(t, before[idx].Time.Equal(after[idx].Time))
+		before[idx].Time, after[idx].Time = time.Time{}, time.Time{}
+	}
+	assert.Equal(t, before, after)
+
+	restored.Undo()
+	assert.Equal(t, text, restored.String())
+
+	restored.GotoState(1)
+	assert.Equal(t, `this is my piece
+table testing
+document i want to see how it fares`, restored.String())
+
+	restored.GotoState(2)
+	assert.Equal(t, output, restored.String())
+}
+
+func TestUnicodeColumns(t *testing.T) {
+	text := `func größe() {
+	// 日本語 comment
+}`
+
+	table := MakePieceTable(text)
+	assert.Equal(t, 14, table.LineLen(0))
+	assert.Equal(t, 16, table.LineBytes(0))
+	assert.Equal(t, 'ö', table.Index(0, 7))
+	assert.Equal(t, 'e', table.Index(0, 9))
+	assert.Equal(t, utf8.RuneError, table.Index(0, 14))
+
+	// delete the ß
+	table.Delete(0, 9)
+	assert.Equal(t, "func gröe() {", table.Line(0))
+
+	end := table.InsertAt(Pos{1, 5}, "本\n語")
+	assert.Equal(t, Pos{2, 1}, end)
+	assert.Equal(t, "\t// 日本\n語本語 comment", table.Line(1)+"\n"+table.Line(2))
+
+	assert.Equal(t, 7, table.ByteCol(1, 5))
+	assert.Equal(t, 5, table.RuneCol(1, 7))
+	assert.Equal(t, 4, table.RuneCol(1, 6))
+
+	line, col := table.OffsetLine(table.LineOffset(2) + 3)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, 1, col)
+
+	table.Undo()
+	table.Undo()
+	assert.Equal(t, text, table.String())
+}
+
+func TestSnapshot(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares`
+
+	table := MakePieceTable(text)
+	snap := table.Snapshot()
+	assert.Equal(t, table.Version(), snap.Version())
+
+	done := make(chan string)
+	go func() {
+		data, err := ioutil.ReadAll(snap.NewReader())
+		assert.NoError(t, err)
+		done <- string(data)
+	}()
+
+	for i := 0; i < 100; i++ {
+		table.InsertAt(Pos{1, 0}, "line\n")
+	}
+	table.SetLine(0, "changed")
+
+	assert.Equal(t, text, <-done)
+	assert.Equal(t, text, snap.String())
+	assert.Equal(t, len(text), snap.Len())
+	assert.Equal(t, 2, snap.LineCount())
+	assert.Equal(t, "this is my testing", snap.Line(0))
+	assert.NotEqual(t, table.Version(), snap.Version())
+
+	latest := table.Snapshot()
+	assert.Equal(t, table.String(), latest.String())
+
+	var lines []string
+	latest.Lines(100, func(idx int, line string) bool {
+		lines = append(lines, line)
+		return true
+	})
+	assert.Equal(t, []string{"line", "document i want to see how it fares"}, lines)
+}
+
+func TestWriteTo(t *testing.T) {
+	text := `this is my testing
+document i want to see how it fares`
+
+	table := MakePieceTable(text)
+	table.InsertAt(Pos{1, 0}, "a new line\n")
+
+	var buf bytes.Buffer
+	n, err := table.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, table.String(), buf.String())
+
+	data, err := ioutil.ReadAll(io.LimitReader(table.NewReader(), 12))
+	assert.NoError(t, err)
+	assert.Equal(t, "this is my t", string(data))
+}
+
+func TestLoadFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "phi-load-")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	var text strings.Builder
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&text, "line %d of the log\n", i)
+	}
+	f.WriteString(text.String())
+	f.Close()
+
+	lazyLoadSize, firstChunkSize = 1024, 64
+	defer func() {
+		lazyLoadSize, firstChunkSize = 16<<20, 64<<10
+	}()
+
+	table, err := LoadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "line 0 of the log", table.Line(0))
+
+	// nothing can be changed until it has loaded
+	assert.True(t, table.ReadOnly())
+	table.InsertAt(Pos{0, 0}, "hello")
+	assert.Equal(t, "line 0 of the log", table.Line(0))
+
+	for {
+		loading, progress := table.Loading()
+		if !loading {
+			break
+		}
+		assert.True(t, progress >= 0 && progress <= 1)
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.False(t, table.ReadOnly())
+	assert.False(t, table.MixedLineEndings())
+	assert.Equal(t, 100001, table.LineCount())
+	assert.Equal(t, text.String(), table.String())
+
+	table.InsertAt(Pos{0, 0}, "hello ")
+	assert.Equal(t, "hello line 0 of the log", table.Line(0))
+}
+
+func TestLoadFileTruncated(t *testing.T) {
+	f, err := ioutil.TempFile("", "phi-load-")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	var text strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&text, "line %d\r\n", i)
+	}
+	f.WriteString(text.String())
+	f.Close()
+
+	lazyLoadSize, firstChunkSize = 1024, 64
+	defer func() {
+		lazyLoadSize, firstChunkSize = 16<<20, 64<<10
+	}()
+
+	table, err := LoadFile(f.Name())
+	assert.NoError(t, err)
+
+	// the first chunk never stops half way through a line
+	last := table.LineCount() - 1
+	assert.Equal(t, fmt.Sprintf("line %d", last), table.Line(last))
+
+	for {
+		if loading, _ := table.Loading(); !loading {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// another program cutting the file short
+	// must not change what we have loaded.
+	assert.NoError(t, os.Truncate(f.Name(), 0))
+	assert.Equal(t, "line 0", table.Line(0))
+	assert.Equal(t, 1001, table.LineCount())
+	assert.Equal(t, CRLF, table.LineEnding())
+	assert.Equal(t, strings.ReplaceAll(text.String(), "\r\n", "\n"), table.String())
+}
+
+func TestLineEndings(t *testing.T) {
+	text := "first line\r\nsecond line\r\n"
+
+	table := MakePieceTable(text)
+	assert.Equal(t, CRLF, table.LineEnding())
+	assert.Equal(t, 3, table.LineCount())
+	assert.Equal(t, "first line", table.Line(0))
+	assert.Equal(t, 10, table.LineLen(0))
+
+	// pasted text is split on any line break
+	table.InsertAt(Pos{1, 0}, "a\nb\r\nc\r")
+	assert.Equal(t, "a", table.Line(1))
+	assert.Equal(t, "b", table.Line(2))
+	assert.Equal(t, "c", table.Line(3))
+	assert.Equal(t, "second line", table.Line(4))
+
+	var buf bytes.Buffer
+	table.WriteTo(&buf)
+	assert.Equal(t, "first line\r\na\r\nb\r\nc\r\nsecond line\r\n", buf.String())
+	assert.Equal(t, buf.Len(), table.Snapshot().Len())
+
+	table.SetLineEnding(LF)
+	buf.Reset()
+	table.WriteTo(&buf)
+	assert.Equal(t, "first line\na\nb\nc\nsecond line\n", buf.String())
+
+	assert.Equal(t, CR, MakePieceTable("old\rmac\r").LineEnding())
+	assert.Equal(t, LF, MakePieceTable("no line breaks").LineEnding())
+
+	assert.False(t, MakePieceTable(text).MixedLineEndings())
+	assert.False(t, MakePieceTable("old\rmac\r").MixedLineEndings())
+
+	// the edits above don't count, it's only what was loaded
+	assert.False(t, table.MixedLineEndings())
+
+	mixed := MakePieceTable("one\r\ntwo\nthree\r\n")
+	assert.True(t, mixed.MixedLineEndings())
+	assert.Equal(t, CRLF, mixed.LineEnding())
+
+	mixed.SetLineEnding(CRLF)
+	assert.False(t, mixed.MixedLineEndings())
+}
+
+func TestEditedLines(t *testing.T) {
+	table := MakePieceTable("one\ntwo\nthree\nfour")
+	table.InsertAt(Pos{0, 3}, "!")
+	mark := table.EditMark()
+
+	table.InsertAt(Pos{2, 0}, "a ")
+	table.InsertAt(Pos{3, 4}, "\nfive")
+	table.Delete(1, 3)
+	table.SwapLines(0, 1)
+
+	assert.Equal(t, []int{2, 4}, table.EditedLines(mark))
+	assert.Equal(t, []int{1, 2, 4}, table.EditedLines(0))
+}
+
+func TestLines(t *testing.T) {
+	table := MakePieceTable("héllo\nworld")
+	table.InsertAt(Pos{0, 5}, "!")
+
+	lines := table.Lines()
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "héllo!", lines[0].Buffer())
+		assert.Equal(t, "world", lines[1].String())
+	}
+}
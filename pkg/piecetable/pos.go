@@ -0,0 +1,63 @@
+package piecetable
+
+// Pos is a position in the piece table, a line and the
+// column within that line. the column counts characters
+// (runes) rather than bytes so a position can never point
+// into the middle of a character.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+// Before returns if this position comes
+// before the other position.
+func (p Pos) Before(other Pos) bool {
+	if p.Line != other.Line {
+		return p.Line < other.Line
+	}
+	return p.Col < other.Col
+}
+
+// clamp makes sure the position
+// points into the table.
+func (p *PieceTable) clamp(pos Pos) Pos {
+	if pos.Line < 0 {
+		return Pos{}
+	}
+	if count := p.LineCount(); pos.Line >= count {
+		return Pos{count - 1, p.LineLen(count - 1)}
+	}
+	if pos.Col < 0 {
+		pos.Col = 0
+	}
+	if lineLen := p.LineLen(pos.Line); pos.Col > lineLen {
+		pos.Col = lineLen
+	}
+	return pos
+}
+
+// toBytes converts the column of the
+// position into a byte offset in its line.
+func (p *PieceTable) toBytes(pos Pos) Pos {
+	return Pos{pos.Line, getLine(p.root, pos.Line).byteOffset(pos.Col)}
+}
+
+// toColumn converts a position with a byte
+// offset into one with a column.
+func (p *PieceTable) toColumn(pos Pos) Pos {
+	return Pos{pos.Line, getLine(p.root, pos.Line).column(pos.Col)}
+}
+
+// endOf returns the position at the end of the given
+// text if it was inserted at pos, both in bytes.
+func endOf(pos Pos, text [][]span) Pos {
+	last := 0
+	for _, s := range text[len(text)-1] {
+		last += s.length
+	}
+
+	if len(text) == 1 {
+		return Pos{pos.Line, pos.Col + last}
+	}
+	return Pos{pos.Line + len(text) - 1, last}
+}
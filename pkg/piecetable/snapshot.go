@@ -0,0 +1,194 @@
+package piecetable
+
+import (
+	"io"
+	"strings"
+)
+
+// Snapshot is a read only version of the document at
+// the time it was taken. the line tree is never modified
+// and the buffers are only ever appended to, so a snapshot
+// is cheap to take and can be read from another goroutine
+// while the table is being edited.
+type Snapshot struct {
+	root     *lineNode
+	original []byte
+	add      []byte
+	version  uint64
+	ending   LineEnding
+}
+
+// Snapshot returns a snapshot of the current document.
+func (p *PieceTable) Snapshot() *Snapshot {
+	return &Snapshot{
+		root:     p.root,
+		original: p.original,
+		add:      p.add,
+		version:  p.version,
+		ending:   p.ending,
+	}
+}
+
+// Version returns the version of the document, this
+// changes every time the table is modified.
+func (p *PieceTable) Version() uint64 {
+	return p.version
+}
+
+// Version returns the version of the document the snapshot
+// was taken at, if it differs from the tables version then
+// the snapshot is stale.
+func (s *Snapshot) Version() uint64 {
+	return s.version
+}
+
+// NOTE: the lines in the tree point to the table they
+// belong to, so we have to use our own buffers here rather
+// than calling into the line.
+func (s *Snapshot) bytes(sp span) []byte {
+	if sp.buf == originalBuffer {
+		return s.original[sp.start : sp.start+sp.length]
+	}
+	return s.add[sp.start : sp.start+sp.length]
+}
+
+func (s *Snapshot) lineString(line *Line) string {
+	var sb strings.Builder
+	sb.Grow(line.Len())
+	for _, sp := range line.spans {
+		sb.Write(s.bytes(sp))
+	}
+	return sb.String()
+}
+
+// LineCount returns the number of lines in the snapshot.
+func (s *Snapshot) LineCount() int {
+	return s.root.getCount()
+}
+
+// Len returns the length of the document in bytes
+// when it is written out.
+func (s *Snapshot) Len() int {
+	if s.root == nil {
+		return 0
+	}
+	return s.root.getSize() + (s.root.getCount()-1)*len(s.ending.Separator())
+}
+
+func (s *Snapshot) Line(idx int) string {
+	line := getLine(s.root, idx)
+	if line == nil {
+		return ""
+	}
+	return s.lineString(line)
+}
+
+// Lines calls fn for every line from the line
+// number start onwards, until fn returns false.
+func (s *Snapshot) Lines(start int, fn func(idx int, line string) bool) {
+	walkLines(s.root, start, func(idx int, line *Line) bool {
+		return fn(idx, s.lineString(line))
+	})
+}
+
+// String returns the document with each line
+// separated by a newline, whatever the line ending.
+func (s *Snapshot) String() string {
+	var sb strings.Builder
+	sb.Grow(s.root.span())
+	s.Lines(0, func(idx int, line string) bool {
+		if idx > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(line)
+		return true
+	})
+	return sb.String()
+}
+
+// NewReader returns a reader that streams the document
+// without building it up as one string, the lines are
+// separated with the line ending.
+func (s *Snapshot) NewReader() io.Reader {
+	return &snapshotReader{snap: s}
+}
+
+type snapshotReader struct {
+	snap *Snapshot
+
+	// the next line to read and what is left
+	// of the line we are currently reading.
+	line    int
+	pending [][]byte
+}
+
+func (r *snapshotReader) Read(buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		if len(r.pending) == 0 {
+			if !r.nextLine() {
+				break
+			}
+			continue
+		}
+
+		copied := copy(buf[n:], r.pending[0])
+		n += copied
+		if copied == len(r.pending[0]) {
+			r.pending = r.pending[1:]
+		} else {
+			r.pending[0] = r.pending[0][copied:]
+		}
+	}
+
+	if n == 0 && len(buf) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// nextLine queues up the bytes of the next line,
+// preceded by a newline if it isn't the first.
+func (r *snapshotReader) nextLine() bool {
+	line := getLine(r.snap.root, r.line)
+	if line == nil {
+		return false
+	}
+
+	if r.line > 0 {
+		r.pending = append(r.pending, []byte(r.snap.ending.Separator()))
+	}
+	for _, sp := range line.spans {
+		r.pending = append(r.pending, r.snap.bytes(sp))
+	}
+	r.line++
+	return true
+}
+
+// WriteTo writes the document to w span by span,
+// the lines are separated with the line ending.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	var err error
+
+	newline := []byte(s.ending.Separator())
+	write := func(data []byte) bool {
+		var n int
+		n, err = w.Write(data)
+		written += int64(n)
+		return err == nil
+	}
+
+	walkLines(s.root, 0, func(idx int, line *Line) bool {
+		if idx > 0 && !write(newline) {
+			return false
+		}
+		for _, sp := range line.spans {
+			if !write(s.bytes(sp)) {
+				return false
+			}
+		}
+		return true
+	})
+	return written, err
+}
@@ -2,109 +2,417 @@ package piecetable
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"unicode/utf8"
 )
 
+// PieceTable is a piece table. the document is loaded into
+// an original buffer which is never modified, anything typed
+// after that is appended to the add buffer. each line is then
+// a list of spans into these two buffers, and the lines are
+// kept in a balanced tree.
 type PieceTable struct {
-	Lines    []*Line
-	nodes    []*PieceNode
-	redoList []*PieceNode
+	root     *lineNode
+	original []byte
+	add      []byte
+
+	// every version of the document in the undo
+	// tree by sequence number, and the current one.
+	states  []*undoState
+	current *undoState
+
+	// the current undo group and how many
+	// times BeginGroup has been called
+	group      uint64
+	groupDepth int
+
+	// incremented on every change to the document
+	version uint64
+
+	// the lines are stored without their separator,
+	// this is put back when the document is written out.
+	ending LineEnding
+
+	// if the document had more than one line ending
+	mixed bool
+
+	// the loader if the file is still being indexed.
+	loader *loader
 }
 
 func MakePieceTable(data string) *PieceTable {
-	readStrings := strings.Split(data, "\n")
-
-	lines := make([]*Line, len(readStrings))
 	table := &PieceTable{
-		lines,
-		[]*PieceNode{},
-		[]*PieceNode{},
+		original: []byte(data),
 	}
+	table.resetHistory()
+	table.ending = detectLineEnding(table.original)
+	lines, seen := table.indexLines(table.original, len(table.original), nil)
+	table.root = buildLines(lines)
+	table.mixed = mixedEndings(seen)
+	return table
+}
 
-	for idx, data := range readStrings {
-		lines[idx] = NewLine(data, table)
+func (p *PieceTable) appendAdd(data string) span {
+	s := span{addBuffer, len(p.add), len(data)}
+	p.add = append(p.add, data...)
+	return s
+}
+
+func (p *PieceTable) bytes(s span) []byte {
+	if s.buf == originalBuffer {
+		return p.original[s.start : s.start+s.length]
 	}
+	return p.add[s.start : s.start+s.length]
+}
 
-	return table
+// splitLines splits the span on every line break
+// into a list of spans, one for each line.
+func (p *PieceTable) splitLines(s span) [][]span {
+	result := [][]span{nil}
+
+	data := p.bytes(s)
+	start := 0
+	for {
+		end, sepLen := nextLineBreak(data, start)
+		if end > start {
+			result[len(result)-1] = []span{{s.buf, s.start + start, end - start}}
+		}
+		if sepLen == 0 {
+			return result
+		}
+		result = append(result, nil)
+		start = end + sepLen
+	}
 }
 
-func (p *PieceTable) Redo() *PieceNode {
-	if len(p.redoList) == 0 {
-		return nil
+func (p *PieceTable) linesString(text [][]span) string {
+	var sb strings.Builder
+	for idx, spans := range text {
+		if idx > 0 {
+			sb.WriteByte('\n')
+		}
+		for _, s := range spans {
+			sb.Write(p.bytes(s))
+		}
 	}
+	return sb.String()
+}
 
-	action := p.redoList[len(p.redoList)-1]
-	p.redoList = p.redoList[:len(p.redoList)-1]
+// replace cuts out the text between start and end and puts
+// the given text in its place. the text that was removed is
+// returned as a list of spans per line.
+func (p *PieceTable) replace(start, end Pos, text [][]span) [][]span {
+	first := getLine(p.root, start.Line)
+	last := getLine(p.root, end.Line)
+
+	removed := make([][]span, 0, end.Line-start.Line+1)
+	for idx := start.Line; idx <= end.Line; idx++ {
+		line := getLine(p.root, idx)
+
+		from, to := 0, line.Len()
+		if idx == start.Line {
+			from = start.Col
+		}
+		if idx == end.Line {
+			to = end.Col
+		}
+		removed = append(removed, line.slice(from, to))
+	}
 
-	actionIndex := len(p.nodes)
-	p.nodes = append(p.nodes, action)
+	lines := make([]*Line, len(text))
+	for idx, spans := range text {
+		var result []span
+		if idx == 0 {
+			result = appendSpans(result, first.slice(0, start.Col)...)
+		}
+		result = appendSpans(result, spans...)
+		if idx == len(text)-1 {
+			result = appendSpans(result, last.slice(end.Col, last.Len())...)
+		}
+		lines[idx] = newLineFromSpans(result, p)
+	}
 
-	line := p.Lines[action.Index]
-	line.mods[actionIndex] = true
+	p.root = setLine(p.root, start.Line, lines[0])
+	for idx := start.Line; idx < end.Line; idx++ {
+		p.root = removeLine(p.root, start.Line+1)
+	}
+	for idx, line := range lines[1:] {
+		p.root = insertLine(p.root, start.Line+1+idx, line)
+	}
 
-	return action
+	p.version++
+	return removed
 }
 
-func (p *PieceTable) Undo() *PieceNode {
-	if len(p.nodes) == 0 {
-		return nil
+// addText appends the text to the add buffer and
+// returns the spans for each line of it.
+func (p *PieceTable) addText(text string) [][]span {
+	if len(text) == 0 {
+		return [][]span{nil}
 	}
+	return p.splitLines(p.appendAdd(text))
+}
+
+// record applies the change to the table and pushes it onto
+// the undo history. every edit to the table goes through here,
+// so the history is a log of every change made to the document.
+func (p *PieceTable) record(start, end Pos, text [][]span) *PieceNode {
+	node := NewPiece(p.linesString(text), start.Line, start.Col)
 
-	nodeIndex := len(p.nodes) - 1
+	node.inserted = text
+	node.removed = p.replace(start, end, node.inserted)
+	node.Removed = p.linesString(node.removed)
 
-	// get the value we pop
-	change := p.nodes[nodeIndex]
+	if p.groupDepth == 0 {
+		p.group++
+	}
+	node.group = p.group
+
+	p.push(node)
+	return node
+}
 
-	// remove the node index from
-	// the mods (i.e. a dangling
-	// pointer)
-	line := p.Lines[change.Index]
-	delete(line.mods, nodeIndex)
+// InsertAt inserts the text at the given position, the
+// text can span multiple lines. the position at the end of
+// the inserted text is returned.
+func (p *PieceTable) InsertAt(pos Pos, text string) Pos {
+	pos = p.clamp(pos)
+	if len(text) == 0 || p.ReadOnly() {
+		return pos
+	}
 
-	// pop the most recent change
-	p.nodes = p.nodes[:nodeIndex]
+	start := p.toBytes(pos)
+	node := p.record(start, start, p.addText(text))
+	return p.toColumn(endOf(start, node.inserted))
+}
 
-	// append it so we can redo it later if necessary
-	p.redoList = append(p.redoList, change)
+// DeleteRange removes all of the text between the start
+// and end position, joining lines together if necessary.
+// the text that was removed is returned.
+func (p *PieceTable) DeleteRange(start, end Pos) string {
+	start, end = p.clamp(start), p.clamp(end)
+	if end.Before(start) {
+		start, end = end, start
+	}
+	if start == end || p.ReadOnly() {
+		return ""
+	}
 
-	return change
+	return p.record(p.toBytes(start), p.toBytes(end), [][]span{nil}).Removed
 }
 
+// Delete removes the character before idx.
 func (p *PieceTable) Delete(line int, idx int) {
-	node := NewPiece("", line, idx)
-	node.Length = -1
-	p.Lines[line].AppendNode(node)
+	p.DeleteRange(Pos{line, idx - 1}, Pos{line, idx})
 }
 
-// TODO this builds the line and indexes it.
+// Index returns the character at the given column,
+// or utf8.RuneError if there is no such character.
 func (p *PieceTable) Index(line int, idx int) rune {
-	r, _ := utf8.DecodeLastRuneInString(p.Lines[line].String()[idx:])
+	l := getLine(p.root, line)
+	if l == nil || idx < 0 || idx >= l.RuneCount() {
+		return utf8.RuneError
+	}
+
+	// TODO this builds the line and indexes it.
+	r, _ := utf8.DecodeRuneInString(l.String()[l.byteOffset(idx):])
 	return r
 }
 
+// Insert inserts the value at the given line and column.
 func (p *PieceTable) Insert(val string, line int, idx int) {
-	node := NewPiece(val, line, idx)
-	p.Lines[line].AppendNode(node)
+	p.InsertAt(Pos{line, idx}, val)
+}
+
+// Lines returns every line in the table. the lines are
+// never modified, an edit replaces them with new ones.
+//
+// Deprecated: this used to be a field, use Line or
+// a Snapshot to get at the lines instead.
+func (p *PieceTable) Lines() []*Line {
+	lines := make([]*Line, 0, p.LineCount())
+	walkLines(p.root, 0, func(_ int, line *Line) bool {
+		lines = append(lines, line)
+		return true
+	})
+	return lines
+}
+
+// LineCount returns the number of lines in the table.
+func (p *PieceTable) LineCount() int {
+	return p.root.getCount()
 }
 
 func (p *PieceTable) Line(idx int) string {
-	return p.Lines[idx].String()
+	return getLine(p.root, idx).String()
 }
 
-func (p *PieceTable) String() string {
-	var result string
-	for idx, line := range p.Lines {
+// LineLen returns the number of characters in the given line.
+func (p *PieceTable) LineLen(idx int) int {
+	return getLine(p.root, idx).RuneCount()
+}
+
+// LineBytes returns the length of the given line in bytes.
+func (p *PieceTable) LineBytes(idx int) int {
+	return getLine(p.root, idx).Len()
+}
+
+// ByteCol converts a column in the given line into
+// a byte offset, columns past the end are clamped.
+func (p *PieceTable) ByteCol(line, col int) int {
+	l := getLine(p.root, line)
+	if l == nil {
+		return 0
+	}
+	return l.byteOffset(col)
+}
+
+// RuneCol converts a byte offset in the given line into a
+// column, offsets in the middle of a character are rounded
+// down to the start of it.
+func (p *PieceTable) RuneCol(line, offs int) int {
+	l := getLine(p.root, line)
+	if l == nil {
+		return 0
+	}
+	return l.column(offs)
+}
+
+// LineOffset returns the byte offset of the start of the
+// given line in the document.
+func (p *PieceTable) LineOffset(line int) int {
+	return lineOffset(p.root, line)
+}
+
+// OffsetLine converts a byte offset in the document
+// into a line and the column within that line.
+func (p *PieceTable) OffsetLine(offs int) (int, int) {
+	line, col := offsetLine(p.root, offs)
+	return line, getLine(p.root, line).column(col)
+}
+
+// InsertLine inserts a new line so that it
+// becomes line number idx.
+func (p *PieceTable) InsertLine(idx int, val string) {
+	count := p.LineCount()
+	if idx < count {
+		p.InsertAt(Pos{idx, 0}, val+"\n")
+		return
+	}
+
+	// there is no line to push down so we
+	// add it after the end of the last line.
+	p.InsertAt(Pos{count - 1, p.LineLen(count - 1)}, "\n"+val)
+}
+
+// RemoveLine removes the given line, the last
+// line of the table is cleared rather than removed.
+func (p *PieceTable) RemoveLine(idx int) {
+	start := Pos{idx, 0}
+	end := Pos{idx + 1, 0}
+
+	// there is no line after this one so we take the
+	// newline before it instead.
+	if count := p.LineCount(); idx >= count-1 {
+		end = Pos{idx, p.LineLen(idx)}
 		if idx > 0 {
-			result += string('\n')
+			start = Pos{idx - 1, p.LineLen(idx - 1)}
 		}
-		result += fmt.Sprintf(line.String())
 	}
-	return result
+
+	p.DeleteRange(start, end)
 }
 
-func (p *PieceTable) Print() {
-	for _, line := range p.Lines {
-		fmt.Println(line.Buffer)
+// SetLine replaces the contents of the given line.
+func (p *PieceTable) SetLine(idx int, val string) {
+	if idx < 0 || idx >= p.LineCount() || p.ReadOnly() {
+		return
+	}
+	p.record(Pos{idx, 0}, Pos{idx, p.LineBytes(idx)}, p.addText(val))
+}
+
+// SwapLines swaps the lines a and b around.
+func (p *PieceTable) SwapLines(a, b int) {
+	if a > b {
+		a, b = b, a
+	}
+	if a == b || a < 0 || b >= p.LineCount() || p.ReadOnly() {
+		return
 	}
+
+	// the lines are re-arranged from their
+	// existing spans, nothing new is added
+	text := make([][]span, 0, b-a+1)
+	text = append(text, getLine(p.root, b).spans)
+	for idx := a + 1; idx < b; idx++ {
+		text = append(text, getLine(p.root, idx).spans)
+	}
+	text = append(text, getLine(p.root, a).spans)
+
+	p.record(Pos{a, 0}, Pos{b, p.LineBytes(b)}, text)
+}
+
+// EditMark marks a point in the
+// tables history, see EditedLines.
+type EditMark int
+
+// EditMark returns a mark for the table as it is now.
+func (p *PieceTable) EditMark() EditMark {
+	return EditMark(len(p.add))
+}
+
+// EditedLines returns the lines that have had text typed into
+// them since the mark was made. lines that have only had text
+// deleted from them or have been moved aren't included.
+func (p *PieceTable) EditedLines(since EditMark) []int {
+	var lines []int
+	walkLines(p.root, 0, func(idx int, line *Line) bool {
+		for _, s := range line.spans {
+			if s.buf == addBuffer && s.start+s.length > int(since) {
+				lines = append(lines, idx)
+				break
+			}
+		}
+		return true
+	})
+	return lines
+}
+
+// String returns the document with each line
+// separated by a newline, whatever the line ending.
+func (p *PieceTable) String() string {
+	var sb strings.Builder
+	sb.Grow(p.root.span())
+
+	walkLines(p.root, 0, func(idx int, line *Line) bool {
+		if idx > 0 {
+			sb.WriteByte('\n')
+		}
+		for _, s := range line.spans {
+			sb.Write(p.bytes(s))
+		}
+		return true
+	})
+	return sb.String()
+}
+
+// WriteTo writes the document to w without building it
+// up as a string first, the lines are separated with the
+// tables line ending.
+func (p *PieceTable) WriteTo(w io.Writer) (int64, error) {
+	return p.Snapshot().WriteTo(w)
+}
+
+// NewReader returns a reader over the document as it
+// is now, later edits wont be seen by the reader.
+func (p *PieceTable) NewReader() io.Reader {
+	return p.Snapshot().NewReader()
+}
+
+func (p *PieceTable) Print() {
+	walkLines(p.root, 0, func(_ int, line *Line) bool {
+		fmt.Println(line.String())
+		return true
+	})
 }
@@ -0,0 +1,261 @@
+package piecetable
+
+// lineNode is a node in an AVL tree of lines ordered by
+// line number. each node keeps the number of lines and bytes
+// in its subtree so we can go from a line to a byte offset
+// (and back) in O(log n).
+//
+// nodes are never modified once they have been built, an
+// edit copies the path from the root down to the changed
+// line instead. this means an old root is still a valid
+// version of the document.
+type lineNode struct {
+	line        *Line
+	left, right *lineNode
+	height      int
+
+	// number of lines in this subtree
+	count int
+
+	// number of bytes in this subtree,
+	// not including the line separators.
+	size int
+}
+
+func (n *lineNode) getHeight() int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func (n *lineNode) getCount() int {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+
+func (n *lineNode) getSize() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// bytes in the subtree when every line is
+// followed by a separator
+func (n *lineNode) span() int {
+	return n.getSize() + n.getCount()
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func newLineNode(line *Line, left, right *lineNode) *lineNode {
+	return &lineNode{
+		line:   line,
+		left:   left,
+		right:  right,
+		height: max(left.getHeight(), right.getHeight()) + 1,
+		count:  left.getCount() + right.getCount() + 1,
+		size:   left.getSize() + right.getSize() + line.Len(),
+	}
+}
+
+// balance builds a new node from the given line and
+// children, rotating if the children differ in height
+// by more than one.
+func balance(line *Line, left, right *lineNode) *lineNode {
+	lh, rh := left.getHeight(), right.getHeight()
+
+	if lh > rh+1 {
+		if left.left.getHeight() >= left.right.getHeight() {
+			return newLineNode(left.line, left.left, newLineNode(line, left.right, right))
+		}
+		lr := left.right
+		return newLineNode(lr.line,
+			newLineNode(left.line, left.left, lr.left),
+			newLineNode(line, lr.right, right))
+	}
+
+	if rh > lh+1 {
+		if right.right.getHeight() >= right.left.getHeight() {
+			return newLineNode(right.line, newLineNode(line, left, right.left), right.right)
+		}
+		rl := right.left
+		return newLineNode(rl.line,
+			newLineNode(line, left, rl.left),
+			newLineNode(right.line, rl.right, right.right))
+	}
+
+	return newLineNode(line, left, right)
+}
+
+// buildLines builds a perfectly balanced tree
+// from the given lines in O(n).
+func buildLines(lines []*Line) *lineNode {
+	if len(lines) == 0 {
+		return nil
+	}
+	mid := len(lines) / 2
+	return newLineNode(lines[mid], buildLines(lines[:mid]), buildLines(lines[mid+1:]))
+}
+
+func getLine(n *lineNode, idx int) *Line {
+	for n != nil {
+		leftCount := n.left.getCount()
+		switch {
+		case idx < leftCount:
+			n = n.left
+		case idx == leftCount:
+			return n.line
+		default:
+			idx -= leftCount + 1
+			n = n.right
+		}
+	}
+	return nil
+}
+
+func setLine(n *lineNode, idx int, line *Line) *lineNode {
+	if n == nil {
+		return nil
+	}
+
+	leftCount := n.left.getCount()
+	switch {
+	case idx < leftCount:
+		return newLineNode(n.line, setLine(n.left, idx, line), n.right)
+	case idx == leftCount:
+		return newLineNode(line, n.left, n.right)
+	default:
+		return newLineNode(n.line, n.left, setLine(n.right, idx-leftCount-1, line))
+	}
+}
+
+// insertLine inserts the line so that it
+// becomes line number idx.
+func insertLine(n *lineNode, idx int, line *Line) *lineNode {
+	if n == nil {
+		return newLineNode(line, nil, nil)
+	}
+
+	leftCount := n.left.getCount()
+	if idx <= leftCount {
+		return balance(n.line, insertLine(n.left, idx, line), n.right)
+	}
+	return balance(n.line, n.left, insertLine(n.right, idx-leftCount-1, line))
+}
+
+func removeLine(n *lineNode, idx int) *lineNode {
+	if n == nil {
+		return nil
+	}
+
+	leftCount := n.left.getCount()
+	switch {
+	case idx < leftCount:
+		return balance(n.line, removeLine(n.left, idx), n.right)
+	case idx > leftCount:
+		return balance(n.line, n.left, removeLine(n.right, idx-leftCount-1))
+	}
+
+	if n.left == nil {
+		return n.right
+	}
+	if n.right == nil {
+		return n.left
+	}
+
+	// replace this node with its successor
+	successor := getLine(n.right, 0)
+	return balance(successor, n.left, removeLine(n.right, 0))
+}
+
+// lineOffset returns the byte offset that the given line
+// starts at, counting a single byte for each line separator.
+func lineOffset(n *lineNode, idx int) int {
+	offs := 0
+	for n != nil {
+		leftCount := n.left.getCount()
+		switch {
+		case idx < leftCount:
+			n = n.left
+		case idx == leftCount:
+			return offs + n.left.span()
+		default:
+			offs += n.left.span() + n.line.Len() + 1
+			idx -= leftCount + 1
+			n = n.right
+		}
+	}
+	return offs
+}
+
+// offsetLine converts a byte offset into a line and
+// the byte column in that line. offsets past the end
+// are clamped to the end of the last line.
+func offsetLine(n *lineNode, offs int) (int, int) {
+	line := 0
+	for n != nil {
+		leftSpan := n.left.span()
+		if offs < leftSpan {
+			n = n.left
+			continue
+		}
+
+		offs -= leftSpan
+		line += n.left.getCount()
+		if offs <= n.line.Len() || n.right == nil {
+			if offs > n.line.Len() {
+				offs = n.line.Len()
+			}
+			return line, offs
+		}
+
+		offs -= n.line.Len() + 1
+		line++
+		n = n.right
+	}
+	return line, 0
+}
+
+// walkLines calls fn for every line from the line
+// number start onwards, until fn returns false.
+func walkLines(n *lineNode, start int, fn func(idx int, line *Line) bool) bool {
+	return walkFrom(n, start, 0, fn)
+}
+
+func walkFrom(n *lineNode, start, base int, fn func(int, *Line) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	leftCount := n.left.getCount()
+	if start < leftCount {
+		if !walkFrom(n.left, start, base, fn) {
+			return false
+		}
+	}
+
+	idx := base + leftCount
+	if start <= leftCount {
+		if !fn(idx, n.line) {
+			return false
+		}
+	}
+
+	return walkFrom(n.right, start-leftCount-1, idx+1, fn)
+}